@@ -0,0 +1,403 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+typedef struct {
+	const char *contents;
+	const char *author;
+	int64_t modified;
+	fz_rect bounds;
+	fz_rect popup_bounds;
+	float color[4];
+	float opacity;
+	int subtype;
+	int color_n;
+	int quad_count;
+	int ink_count;
+	int vertex_count;
+	int id;
+	int in_reply_to;
+	int popup;
+} annot_info;
+
+// Fills info with the details of annot. The strings it points to belong to the annotation, so they must be copied before
+// the annotation changes. Returns 0 if it threw.
+int wrapped_annot_info(fz_context *ctx, pdf_annot *annot, annot_info *info) {
+	pdf_obj *obj;
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		obj = pdf_annot_obj(ctx, annot);
+		info->id = pdf_to_num(ctx, obj);
+		info->subtype = pdf_annot_type(ctx, annot);
+		info->bounds = pdf_bound_annot(ctx, annot);
+		info->contents = pdf_annot_contents(ctx, annot);
+		info->modified = pdf_annot_modification_date(ctx, annot);
+		info->opacity = pdf_annot_opacity(ctx, annot);
+		pdf_annot_color(ctx, annot, &info->color_n, info->color);
+		info->in_reply_to = pdf_to_num(ctx, pdf_dict_get(ctx, obj, PDF_NAME(IRT)));
+		// These throw for the types of annotation that cannot have them, so they are only asked for when they can.
+		if (pdf_annot_has_author(ctx, annot)) {
+			info->author = pdf_annot_author(ctx, annot);
+		}
+		if (pdf_annot_has_popup(ctx, annot)) {
+			info->popup = pdf_to_num(ctx, pdf_dict_get(ctx, obj, PDF_NAME(Popup)));
+			if (info->popup != 0) {
+				info->popup_bounds = pdf_annot_popup(ctx, annot);
+			}
+		}
+		if (pdf_annot_has_quad_points(ctx, annot)) {
+			info->quad_count = pdf_annot_quad_point_count(ctx, annot);
+		}
+		if (pdf_annot_has_ink_list(ctx, annot)) {
+			info->ink_count = pdf_annot_ink_list_count(ctx, annot);
+		}
+		if (pdf_annot_has_line(ctx, annot)) {
+			info->vertex_count = 2;
+		} else if (pdf_annot_has_vertices(ctx, annot)) {
+			info->vertex_count = pdf_annot_vertex_count(ctx, annot);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Fills quads with the first count quad points of annot. Returns 0 if it threw.
+int wrapped_annot_quad_points(fz_context *ctx, pdf_annot *annot, fz_quad *quads, int count) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		for (int i = 0; i < count; i++) {
+			quads[i] = pdf_annot_quad_point(ctx, annot, i);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Fills counts with the number of points in each of the first count ink strokes of annot. Returns 0 if it threw.
+int wrapped_annot_ink_counts(fz_context *ctx, pdf_annot *annot, int *counts, int count) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		for (int i = 0; i < count; i++) {
+			counts[i] = pdf_annot_ink_list_stroke_count(ctx, annot, i);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Fills points with the first counts[i] points of each of the first count ink strokes of annot, one stroke after
+// another. Returns 0 if it threw.
+int wrapped_annot_ink_points(fz_context *ctx, pdf_annot *annot, const int *counts, int count, fz_point *points) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		for (int i = 0; i < count; i++) {
+			for (int k = 0; k < counts[i]; k++) {
+				*points++ = pdf_annot_ink_list_stroke_vertex(ctx, annot, i, k);
+			}
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Fills points with the first count vertices of annot, which for a Line annotation are its two end points. Returns 0
+// if it threw.
+int wrapped_annot_vertices(fz_context *ctx, pdf_annot *annot, fz_point *points, int count) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		if (pdf_annot_has_line(ctx, annot)) {
+			if (count >= 2) {
+				pdf_annot_line(ctx, annot, &points[0], &points[1]);
+			}
+		} else {
+			for (int i = 0; i < count; i++) {
+				points[i] = pdf_annot_vertex(ctx, annot, i);
+			}
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+*/
+import "C"
+
+import (
+	"image"
+	"image/color"
+	"time"
+	"unsafe"
+)
+
+// AnnotationType identifies the kind of an Annotation.
+type AnnotationType int
+
+// Possible values for AnnotationType. They match MuPDF's, which follow the annotation subtypes of the PDF
+// specification.
+const (
+	AnnotationUnknown AnnotationType = iota - 1
+	AnnotationText
+	AnnotationLink
+	AnnotationFreeText
+	AnnotationLine
+	AnnotationSquare
+	AnnotationCircle
+	AnnotationPolygon
+	AnnotationPolyLine
+	AnnotationHighlight
+	AnnotationUnderline
+	AnnotationSquiggly
+	AnnotationStrikeOut
+	AnnotationRedact
+	AnnotationStamp
+	AnnotationCaret
+	AnnotationInk
+	AnnotationPopup
+	AnnotationFileAttachment
+	AnnotationSound
+	AnnotationMovie
+	AnnotationRichMedia
+	AnnotationWidget
+	AnnotationScreen
+	AnnotationPrinterMark
+	AnnotationTrapNet
+	AnnotationWatermark
+	Annotation3D
+	AnnotationProjection
+)
+
+var annotationTypeNames = []string{
+	"Text",
+	"Link",
+	"FreeText",
+	"Line",
+	"Square",
+	"Circle",
+	"Polygon",
+	"PolyLine",
+	"Highlight",
+	"Underline",
+	"Squiggly",
+	"StrikeOut",
+	"Redact",
+	"Stamp",
+	"Caret",
+	"Ink",
+	"Popup",
+	"FileAttachment",
+	"Sound",
+	"Movie",
+	"RichMedia",
+	"Widget",
+	"Screen",
+	"PrinterMark",
+	"TrapNet",
+	"Watermark",
+	"3D",
+	"Projection",
+}
+
+// String returns the annotation subtype as the PDF specification names it, such as "Highlight", or "Unknown".
+func (t AnnotationType) String() string {
+	if t < 0 || int(t) >= len(annotationTypeNames) {
+		return "Unknown"
+	}
+	return annotationTypeNames[t]
+}
+
+// Annotation holds a single annotation of a page, such as a reviewer's comment or highlight. The relationships between
+// annotations are given by ID, which is the number of the annotation's object within the document, or 0 if it does not
+// have one of its own.
+type Annotation struct {
+	// Modified is the time the annotation was last modified, or the zero value if it is not recorded.
+	Modified time.Time
+	// Color is the color of the annotation, or nil if it has none. It is a color.Gray, color.NRGBA, or color.CMYK,
+	// depending on how the document specifies it.
+	Color color.Color
+	// Contents is the text of the annotation, such as the comment of a Text annotation.
+	Contents string
+	// Author is the name of the annotation's author, or empty if it is not recorded or the annotation type cannot have
+	// one.
+	Author string
+	// Quads holds up to OverallMaxAnnotationQuads quad points of a text markup annotation, such as a Highlight, which
+	// give the areas of the text it marks.
+	Quads []Quad
+	// Ink holds the strokes of an Ink annotation, each a path of points drawn freehand.
+	Ink [][]Point
+	// Vertices holds the two end points of a Line annotation, or the vertices of a Polygon or PolyLine annotation.
+	Vertices []Point
+	// Bounds is the area the annotation covers.
+	Bounds image.Rectangle
+	// PopupBounds is the area of the annotation's popup, if it has one.
+	PopupBounds image.Rectangle
+	// Type is the kind of annotation.
+	Type AnnotationType
+	// ID identifies the annotation, for use with InReplyTo and Popup.
+	ID int
+	// InReplyTo is the ID of the annotation this one replies to, or 0.
+	InReplyTo int
+	// Popup is the ID of the annotation's Popup annotation, or 0 if it has none. Popup annotations are not returned by
+	// Annotations themselves, as they only hold the position of the popup, which is given by PopupBounds.
+	Popup int
+	// Opacity is the opacity of the annotation, from 0 for transparent to 1 for opaque.
+	Opacity float64
+}
+
+// Annotations returns up to OverallMaxAnnotations annotations of the page, in the pixel space of the page rendered at
+// the requested dpi. Only PDF documents have annotations; for other documents, nil is returned. As with MuPDF's own
+// viewers, links, which are returned by Links instead, Popup annotations, and form fields are not included.
+func (p *Page) Annotations(dpi int) ([]*Annotation, error) {
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return nil, err
+	}
+	return p.doc.loadAnnotations(p.page, dpiToScale(dpi)), nil
+}
+
+// loadAnnotations returns up to OverallMaxAnnotations annotations of the page. Annotations whose details cannot be read
+// are skipped. The caller must hold d.lock and must have checked that the document has not been released.
+func (d *Document) loadAnnotations(page *C.fz_page, scale float64) []*Annotation {
+	pdfPage := C.pdf_page_from_fz_page(d.ctx, page)
+	if pdfPage == nil || OverallMaxAnnotations < 1 {
+		return nil
+	}
+	var annotations []*Annotation
+	for annot := C.pdf_first_annot(d.ctx, pdfPage); annot != nil; annot = C.pdf_next_annot(d.ctx, annot) {
+		if a := d.loadAnnotation(annot, scale); a != nil {
+			if annotations = append(annotations, a); len(annotations) >= OverallMaxAnnotations {
+				break
+			}
+		}
+	}
+	return annotations
+}
+
+// loadAnnotation returns the details of annot, or nil if they cannot be read. The caller must hold d.lock.
+func (d *Document) loadAnnotation(annot *C.pdf_annot, scale float64) *Annotation {
+	var info C.annot_info
+	if C.wrapped_annot_info(d.ctx, annot, &info) == 0 {
+		return nil
+	}
+	a := &Annotation{
+		Type:      AnnotationType(info.subtype),
+		Bounds:    rectToImage(info.bounds, scale),
+		ID:        int(info.id),
+		InReplyTo: int(info.in_reply_to),
+		Popup:     int(info.popup),
+		Opacity:   float64(info.opacity),
+		Color:     annotationColor(int(info.color_n), info.color),
+	}
+	if a.Type < AnnotationUnknown || int(a.Type) >= len(annotationTypeNames) {
+		a.Type = AnnotationUnknown
+	}
+	if info.contents != nil {
+		a.Contents = sanitizeText(C.GoString(info.contents), nil)
+	}
+	if info.author != nil {
+		a.Author = sanitizeString(info.author)
+	}
+	if info.modified > 0 {
+		a.Modified = time.Unix(int64(info.modified), 0).UTC()
+	}
+	if a.Popup != 0 {
+		a.PopupBounds = rectToImage(info.popup_bounds, scale)
+	}
+	if count := min(int(info.quad_count), OverallMaxAnnotationQuads); count > 0 {
+		quads := make([]C.fz_quad, count)
+		if C.wrapped_annot_quad_points(d.ctx, annot, (*C.fz_quad)(unsafe.Pointer(&quads[0])), C.int(count)) != 0 {
+			a.Quads = make([]Quad, count)
+			for i, q := range quads {
+				a.Quads[i] = scaleQuad(q, scale)
+			}
+		}
+	}
+	if count := int(info.ink_count); count > 0 {
+		a.Ink = d.loadInk(annot, count, scale)
+	}
+	if count := min(int(info.vertex_count), OverallMaxAnnotationPoints); count > 0 {
+		points := make([]C.fz_point, count)
+		if C.wrapped_annot_vertices(d.ctx, annot, (*C.fz_point)(unsafe.Pointer(&points[0])), C.int(count)) != 0 {
+			a.Vertices = scalePoints(points, scale)
+		}
+	}
+	return a
+}
+
+// loadInk returns the first count ink strokes of annot, keeping to OverallMaxAnnotationPoints points in all. The
+// caller must hold d.lock.
+func (d *Document) loadInk(annot *C.pdf_annot, count int, scale float64) [][]Point {
+	counts := make([]C.int, min(count, OverallMaxAnnotationPoints))
+	if len(counts) == 0 || C.wrapped_annot_ink_counts(d.ctx, annot, &counts[0], C.int(len(counts))) == 0 {
+		return nil
+	}
+	total := 0
+	for i, n := range counts {
+		n = C.int(min(max(int(n), 0), OverallMaxAnnotationPoints-total))
+		counts[i] = n
+		total += int(n)
+	}
+	if total == 0 {
+		return nil
+	}
+	points := make([]C.fz_point, total)
+	if C.wrapped_annot_ink_points(d.ctx, annot, &counts[0], C.int(len(counts)), (*C.fz_point)(unsafe.Pointer(&points[0]))) == 0 {
+		return nil
+	}
+	ink := make([][]Point, 0, len(counts))
+	for _, n := range counts {
+		if n > 0 {
+			ink = append(ink, scalePoints(points[:n], scale))
+			points = points[n:]
+		}
+	}
+	return ink
+}
+
+// scalePoints scales MuPDF points by scale into rendered-image pixel space.
+func scalePoints(points []C.fz_point, scale float64) []Point {
+	scaled := make([]Point, len(points))
+	for i, p := range points {
+		scaled[i] = Point{X: float64(p.x) * scale, Y: float64(p.y) * scale}
+	}
+	return scaled
+}
+
+// annotationColor converts the n components of an annotation color, each from 0 to 1, into a color.Color, or nil if
+// there are none.
+func annotationColor(n int, components [4]C.float) color.Color {
+	component := func(i int) uint8 {
+		return uint8(min(max(float64(components[i]), 0), 1)*255 + 0.5)
+	}
+	switch n {
+	case 1:
+		return color.Gray{Y: component(0)}
+	case 3:
+		return color.NRGBA{R: component(0), G: component(1), B: component(2), A: 0xff}
+	case 4:
+		return color.CMYK{C: component(0), M: component(1), Y: component(2), K: component(3)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package pdf_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/richardwilkes/pdf"
+)
+
+// annotationPDF is a minimal one-page document with a reviewer's comment (4) and its popup (5), a highlight (6), a
+// reply to the comment (7), and a link (8). As with internalLinkPDF, MuPDF rebuilds the missing xref.
+const annotationPDF = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Annots [4 0 R 5 0 R 6 0 R 7 0 R 8 0 R] >>
+endobj
+4 0 obj
+<< /Type /Annot /Subtype /Text /Rect [10 170 30 190] /Contents (Please fix this) /T (Reviewer)
+   /M (D:20240102030405Z) /C [1 0 0] /CA 0.5 /Popup 5 0 R >>
+endobj
+5 0 obj
+<< /Type /Annot /Subtype /Popup /Rect [120 120 190 190] /Parent 4 0 R >>
+endobj
+6 0 obj
+<< /Type /Annot /Subtype /Highlight /Rect [10 40 60 50] /QuadPoints [10 50 60 50 10 40 60 40] /C [1 1 0]
+   /T (Reviewer) >>
+endobj
+7 0 obj
+<< /Type /Annot /Subtype /Text /Rect [40 170 60 190] /Contents (Done) /T (Author) /IRT 4 0 R >>
+endobj
+8 0 obj
+<< /Type /Annot /Subtype /Link /Rect [10 10 90 30] /A << /S /URI /URI (http://example.com) >> >>
+endobj
+trailer
+<< /Root 1 0 R /Size 9 >>
+startxref
+0
+%%EOF
+`
+
+func TestAnnotations(t *testing.T) {
+	doc, err := pdf.New([]byte(annotationPDF), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	annotations, err := page.Annotations(72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The popup and the link are not included.
+	if len(annotations) != 3 {
+		t.Fatalf("expected 3 annotations, got %d", len(annotations))
+	}
+	comment, highlight, reply := annotations[0], annotations[1], annotations[2]
+
+	if comment.Type != pdf.AnnotationText || comment.Type.String() != "Text" {
+		t.Errorf("expected a Text annotation, got %v", comment.Type)
+	}
+	if comment.ID != 4 || comment.Contents != "Please fix this" || comment.Author != "Reviewer" {
+		t.Errorf("unexpected comment: %+v", comment)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !comment.Modified.Equal(want) {
+		t.Errorf("expected modified %v, got %v", want, comment.Modified)
+	}
+	if comment.Color != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("expected red, got %v", comment.Color)
+	}
+	if math.Abs(comment.Opacity-0.5) > 0.01 {
+		t.Errorf("expected an opacity of 0.5, got %v", comment.Opacity)
+	}
+	if comment.Popup != 5 || comment.PopupBounds != image.Rect(120, 10, 190, 80) {
+		t.Errorf("expected popup 5 at %v, got %d at %v", image.Rect(120, 10, 190, 80), comment.Popup,
+			comment.PopupBounds)
+	}
+
+	if highlight.Type != pdf.AnnotationHighlight || len(highlight.Quads) != 1 {
+		t.Fatalf("expected a Highlight with 1 quad, got %v with %d", highlight.Type, len(highlight.Quads))
+	}
+	if q := highlight.Quads[0]; q.UL != (pdf.Point{X: 10, Y: 150}) || q.LR != (pdf.Point{X: 60, Y: 160}) {
+		t.Errorf("unexpected highlight quad %+v", q)
+	}
+	if highlight.Color != (color.NRGBA{R: 255, G: 255, A: 255}) || highlight.Opacity != 1 {
+		t.Errorf("expected opaque yellow, got %v at %v", highlight.Color, highlight.Opacity)
+	}
+
+	if reply.InReplyTo != comment.ID || reply.Author != "Author" || reply.Contents != "Done" {
+		t.Errorf("expected a reply to %d, got %+v", comment.ID, reply)
+	}
+
+	// At twice the resolution, positions double.
+	scaled, err := page.Annotations(144)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scaled[1].Quads[0].UL; got != (pdf.Point{X: 20, Y: 300}) {
+		t.Errorf("expected the highlight to start at (20, 300) at 144 dpi, got %v", got)
+	}
+
+	// The quads of each annotation are limited separately from the number of annotations.
+	defer func(prev int) { pdf.OverallMaxAnnotationQuads = prev }(pdf.OverallMaxAnnotationQuads)
+	pdf.OverallMaxAnnotationQuads = 0
+	if annotations, err = page.Annotations(72); err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 3 || len(annotations[1].Quads) != 0 {
+		t.Errorf("expected 3 annotations without quads, got %d", len(annotations))
+	}
+}
@@ -0,0 +1,547 @@
+package pdf
+
+/*
+#include <stdlib.h>
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+// Creates an annotation of the given type on page, with MuPDF's defaults for that type. Returns NULL if it threw. The
+// caller must drop the returned annotation.
+pdf_annot *wrapped_create_annot(fz_context *ctx, pdf_page *page, int subtype) {
+	pdf_annot *annot = NULL;
+	fz_var(annot);
+	fz_try(ctx) {
+		annot = pdf_create_annot(ctx, page, (enum pdf_annot_type)subtype);
+	}
+	fz_catch(ctx) {
+		annot = NULL;
+	}
+	return annot;
+}
+
+// Removes annot from page. Returns 0 if it threw.
+int wrapped_delete_annot(fz_context *ctx, pdf_page *page, pdf_annot *annot) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		pdf_delete_annot(ctx, page, annot);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Sets the rectangle of annot, if its type has one. Returns 0 if it threw.
+int wrapped_set_annot_rect(fz_context *ctx, pdf_annot *annot, fz_rect rect) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		if (pdf_annot_has_rect(ctx, annot)) {
+			pdf_set_annot_rect(ctx, annot, rect);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Replaces the quad points of annot with the n in quads, if its type has them. Returns 0 if it threw.
+int wrapped_set_annot_quad_points(fz_context *ctx, pdf_annot *annot, int n, const fz_quad *quads) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		if (pdf_annot_has_quad_points(ctx, annot)) {
+			pdf_set_annot_quad_points(ctx, annot, n, quads);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Replaces the ink strokes of annot with the n given by counts, which hold the number of points in each, and points,
+// which hold them one stroke after another, if its type has them. Returns 0 if it threw.
+int wrapped_set_annot_ink_list(fz_context *ctx, pdf_annot *annot, int n, const int *counts, const fz_point *points) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		if (pdf_annot_has_ink_list(ctx, annot)) {
+			pdf_set_annot_ink_list(ctx, annot, n, counts, points);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Replaces the vertices of annot with the n in points, if its type has them. A Line annotation takes exactly two, its
+// end points. Returns 0 if it threw.
+int wrapped_set_annot_vertices(fz_context *ctx, pdf_annot *annot, int n, const fz_point *points) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		if (pdf_annot_has_line(ctx, annot)) {
+			if (n == 2) {
+				pdf_set_annot_line(ctx, annot, points[0], points[1]);
+			}
+		} else if (pdf_annot_has_vertices(ctx, annot)) {
+			pdf_set_annot_vertices(ctx, annot, n, points);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Sets the color of annot to the n components in color, or removes it if n is 0. Returns 0 if it threw.
+int wrapped_set_annot_color(fz_context *ctx, pdf_annot *annot, int n, const float *color) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		pdf_set_annot_color(ctx, annot, n, color);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Sets the opacity of annot. Returns 0 if it threw.
+int wrapped_set_annot_opacity(fz_context *ctx, pdf_annot *annot, float opacity) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		pdf_set_annot_opacity(ctx, annot, opacity);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Sets the contents of annot. Returns 0 if it threw.
+int wrapped_set_annot_contents(fz_context *ctx, pdf_annot *annot, const char *text) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		pdf_set_annot_contents(ctx, annot, text);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Sets the author of annot, if its type can have one. Returns 0 if it threw.
+int wrapped_set_annot_author(fz_context *ctx, pdf_annot *annot, const char *author) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		if (pdf_annot_has_author(ctx, annot)) {
+			pdf_set_annot_author(ctx, annot, author);
+		}
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Records that annot was modified at the given time and regenerates its appearance. Returns 0 if it threw.
+int wrapped_finish_annot_edit(fz_context *ctx, pdf_annot *annot, int64_t modified) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		pdf_set_annot_modification_date(ctx, annot, modified);
+		pdf_update_annot(ctx, annot);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+*/
+import "C"
+
+import (
+	"image/color"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+// CreateAnnotation adds a new annotation of the given type to the page, with MuPDF's defaults for that type, such as
+// the yellow of a Highlight, and returns it in the pixel space of the page rendered at the requested dpi. Set its
+// fields and pass it to UpdateAnnotation to finish it; changes to the page are kept in memory until the document is
+// written out with Save. Only PDF documents can have annotations, and Link, Popup, and Widget annotations cannot be
+// created this way.
+func (p *Page) CreateAnnotation(typ AnnotationType, dpi int) (*Annotation, error) {
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return nil, err
+	}
+	pdfPage, err := p.doc.pdfPage(p.page)
+	if err != nil {
+		return nil, err
+	}
+	annot, err := p.doc.createAnnotation(pdfPage, typ)
+	if err != nil {
+		return nil, err
+	}
+	defer C.pdf_drop_annot(p.doc.ctx, annot)
+	if C.wrapped_finish_annot_edit(p.doc.ctx, annot, C.int64_t(time.Now().Unix())) == 0 {
+		return nil, ErrUnableToEditAnnotation
+	}
+	p.doc.pageChanged(p.number)
+	a := p.doc.loadAnnotation(annot, dpiToScale(dpi))
+	if a == nil {
+		return nil, ErrUnableToEditAnnotation
+	}
+	return a, nil
+}
+
+// UpdateAnnotation changes the annotation of the page identified by a.ID to match a, whose positions are in the pixel
+// space of the page rendered at the requested dpi, and returns it as it is afterward. Only its Bounds, Quads, Ink,
+// Vertices, Color, Opacity, Contents, and Author can be changed, and only those that differ from the annotation's
+// current values are written, so positions that are left alone are not disturbed by rounding. Those the annotation's
+// type cannot have are ignored. Its modification time is set to now and its appearance is regenerated.
+func (p *Page) UpdateAnnotation(a *Annotation, dpi int) (*Annotation, error) {
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return nil, err
+	}
+	pdfPage, err := p.doc.pdfPage(p.page)
+	if err != nil {
+		return nil, err
+	}
+	annot := p.doc.findAnnotation(pdfPage, a.ID)
+	if annot == nil {
+		return nil, ErrAnnotationNotFound
+	}
+	scale := dpiToScale(dpi)
+	err = p.doc.applyAnnotation(annot, a, scale)
+	p.doc.pageChanged(p.number)
+	if err != nil {
+		return nil, err
+	}
+	updated := p.doc.loadAnnotation(annot, scale)
+	if updated == nil {
+		return nil, ErrUnableToEditAnnotation
+	}
+	return updated, nil
+}
+
+// DeleteAnnotation removes the annotation of the page with the given ID, along with its popup, if it has one.
+func (p *Page) DeleteAnnotation(id int) error {
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return err
+	}
+	pdfPage, err := p.doc.pdfPage(p.page)
+	if err != nil {
+		return err
+	}
+	annot := p.doc.findAnnotation(pdfPage, id)
+	if annot == nil {
+		return ErrAnnotationNotFound
+	}
+	ok := C.wrapped_delete_annot(p.doc.ctx, pdfPage, annot)
+	p.doc.pageChanged(p.number)
+	if ok == 0 {
+		return ErrUnableToEditAnnotation
+	}
+	return nil
+}
+
+// HighlightSearch looks for needle on every page of the document, matching it as Search does by default, and adds a
+// Highlight annotation with the given color and author over each match, returning how many it added. A match that
+// wraps across lines becomes a single Highlight with one quad per line. Up to OverallMaxHits quads are highlighted on
+// each page. A nil color leaves MuPDF's default yellow, and an empty author leaves it unset. As with Search, the pages
+// are handled one at a time. If an error occurs, the number of Highlights added before it is returned along with it.
+// Only PDF documents can have annotations; for others, ErrNotPDFData is returned.
+func (d *Document) HighlightSearch(needle string, c color.Color, author string) (int, error) {
+	if needle == "" {
+		return 0, nil
+	}
+	s, err := newSearcher(needle, nil)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for pageNumber := 0; ; pageNumber++ {
+		count, done, highlightErr := d.highlightPage(pageNumber, s, c, author)
+		added += count
+		if highlightErr != nil {
+			return added, highlightErr
+		}
+		if done {
+			return added, nil
+		}
+	}
+}
+
+// highlightPage adds a Highlight annotation over each match on the page, returning how many it added, or done set to
+// true if the page number is past the end of the document.
+func (d *Document) highlightPage(pageNumber int, s *searcher, c color.Color, author string) (added int, done bool, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return 0, false, ErrDocumentReleased
+	}
+	if pageNumber >= d.pageCount() {
+		return 0, true, nil
+	}
+	page, err := d.loadPage(pageNumber)
+	if err != nil {
+		return 0, false, err
+	}
+	defer C.fz_drop_page(d.ctx, page)
+	pdfPage, err := d.pdfPage(page)
+	if err != nil {
+		return 0, false, err
+	}
+	displayList := d.newDisplayList(page, nil)
+	if displayList == nil {
+		return 0, false, ErrUnableToExtractText
+	}
+	defer C.fz_drop_display_list(d.ctx, displayList)
+	matches, err := d.search(displayList, s, OverallMaxHits, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(matches) != 0 {
+		defer d.pageChanged(pageNumber)
+	}
+	for _, match := range matches {
+		if err = d.addHighlight(pdfPage, match, c, author); err != nil {
+			return added, false, err
+		}
+		added++
+	}
+	return added, false, nil
+}
+
+// addHighlight adds a Highlight annotation over the quads to the page. The caller must hold d.lock and must call
+// pageChanged afterward.
+func (d *Document) addHighlight(page *C.pdf_page, quads []C.fz_quad, c color.Color, author string) error {
+	annot, err := d.createAnnotation(page, AnnotationHighlight)
+	if err != nil {
+		return err
+	}
+	defer C.pdf_drop_annot(d.ctx, annot)
+	a := d.loadAnnotation(annot, 1)
+	if a == nil {
+		return ErrUnableToEditAnnotation
+	}
+	a.Quads = make([]Quad, len(quads))
+	for i, q := range quads {
+		a.Quads[i] = scaleQuad(q, 1)
+	}
+	if c != nil {
+		a.Color = c
+	}
+	if author != "" {
+		a.Author = author
+	}
+	return d.applyAnnotation(annot, a, 1)
+}
+
+// pdfPage returns the PDF page behind page, or ErrNotPDFData if the document is not a PDF. The caller must hold
+// d.lock.
+func (d *Document) pdfPage(page *C.fz_page) (*C.pdf_page, error) {
+	pdfPage := C.pdf_page_from_fz_page(d.ctx, page)
+	if pdfPage == nil {
+		return nil, ErrNotPDFData
+	}
+	return pdfPage, nil
+}
+
+// createAnnotation adds a new annotation of the given type to the page. The caller must hold d.lock and must drop the
+// returned annotation.
+func (d *Document) createAnnotation(page *C.pdf_page, typ AnnotationType) (*C.pdf_annot, error) {
+	switch typ {
+	case AnnotationLink, AnnotationPopup, AnnotationWidget:
+		return nil, ErrInvalidAnnotationType
+	default:
+		if typ < 0 || int(typ) >= len(annotationTypeNames) {
+			return nil, ErrInvalidAnnotationType
+		}
+	}
+	annot := C.wrapped_create_annot(d.ctx, page, C.int(typ))
+	if annot == nil {
+		return nil, ErrUnableToEditAnnotation
+	}
+	return annot, nil
+}
+
+// findAnnotation returns the annotation of the page with the given ID, or nil if there isn't one. The annotation
+// belongs to the page and must not be dropped. The caller must hold d.lock.
+func (d *Document) findAnnotation(page *C.pdf_page, id int) *C.pdf_annot {
+	if id == 0 {
+		return nil
+	}
+	for annot := C.pdf_first_annot(d.ctx, page); annot != nil; annot = C.pdf_next_annot(d.ctx, annot) {
+		if int(C.pdf_to_num(d.ctx, C.pdf_annot_obj(d.ctx, annot))) == id {
+			return annot
+		}
+	}
+	return nil
+}
+
+// applyAnnotation writes the fields of a that differ from those of annot to it, then records the modification and
+// regenerates its appearance. The caller must hold d.lock and must call pageChanged afterward.
+func (d *Document) applyAnnotation(annot *C.pdf_annot, a *Annotation, scale float64) error {
+	current := d.loadAnnotation(annot, scale)
+	if current == nil {
+		return ErrUnableToEditAnnotation
+	}
+	ok := C.int(1)
+	if a.Bounds != current.Bounds {
+		r := C.fz_rect{
+			x0: C.float(float64(a.Bounds.Min.X) / scale),
+			y0: C.float(float64(a.Bounds.Min.Y) / scale),
+			x1: C.float(float64(a.Bounds.Max.X) / scale),
+			y1: C.float(float64(a.Bounds.Max.Y) / scale),
+		}
+		ok &= C.wrapped_set_annot_rect(d.ctx, annot, r)
+	}
+	if !slices.Equal(a.Quads, current.Quads) {
+		quads := make([]C.fz_quad, len(a.Quads))
+		for i, q := range a.Quads {
+			quads[i] = unscaleQuad(q, scale)
+		}
+		ok &= C.wrapped_set_annot_quad_points(d.ctx, annot, C.int(len(quads)), firstOrNil(quads))
+	}
+	if !slices.EqualFunc(a.Ink, current.Ink, slices.Equal[[]Point]) {
+		counts := make([]C.int, len(a.Ink))
+		var points []C.fz_point
+		for i, stroke := range a.Ink {
+			counts[i] = C.int(len(stroke))
+			points = append(points, unscalePoints(stroke, scale)...)
+		}
+		ok &= C.wrapped_set_annot_ink_list(d.ctx, annot, C.int(len(counts)), firstOrNil(counts), firstOrNil(points))
+	}
+	if !slices.Equal(a.Vertices, current.Vertices) {
+		points := unscalePoints(a.Vertices, scale)
+		ok &= C.wrapped_set_annot_vertices(d.ctx, annot, C.int(len(points)), firstOrNil(points))
+	}
+	// Colors are compared by their components, as the caller's color type may not be comparable.
+	n, components := annotationComponents(a.Color)
+	if currentN, currentComponents := annotationComponents(current.Color); n != currentN || components != currentComponents {
+		ok &= C.wrapped_set_annot_color(d.ctx, annot, C.int(n), &components[0])
+	}
+	if a.Opacity != current.Opacity {
+		ok &= C.wrapped_set_annot_opacity(d.ctx, annot, C.float(min(max(a.Opacity, 0), 1)))
+	}
+	if a.Contents != current.Contents {
+		ok &= d.setAnnotationText(annot, a.Contents, false)
+	}
+	if a.Author != current.Author {
+		ok &= d.setAnnotationText(annot, a.Author, true)
+	}
+	ok &= C.wrapped_finish_annot_edit(d.ctx, annot, C.int64_t(time.Now().Unix()))
+	if ok == 0 {
+		return ErrUnableToEditAnnotation
+	}
+	return nil
+}
+
+// setAnnotationText sets the author of annot, or its contents if author is false. The caller must hold d.lock.
+func (d *Document) setAnnotationText(annot *C.pdf_annot, text string, author bool) C.int {
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+	if author {
+		return C.wrapped_set_annot_author(d.ctx, annot, cText)
+	}
+	return C.wrapped_set_annot_contents(d.ctx, annot, cText)
+}
+
+// pageChanged rebuilds the display lists of the loaded pages with the given page number, so that they show the changes
+// made to it. A page whose display list cannot be rebuilt keeps its old one. The caller must hold d.lock.
+func (d *Document) pageChanged(pageNumber int) {
+	for lp := range d.pages {
+		if lp.number != pageNumber || lp.page == nil {
+			continue
+		}
+		if displayList := d.newDisplayList(lp.page, nil); displayList != nil {
+			C.fz_drop_display_list(d.ctx, lp.displayList)
+			lp.displayList = displayList
+		}
+	}
+}
+
+// annotationComponents converts c into the components of an annotation color, each from 0 to 1, the reverse of
+// annotationColor. A nil color has no components. Colors other than color.Gray and color.CMYK become RGB, without their
+// alpha, which is given by the annotation's opacity instead.
+func annotationComponents(c color.Color) (n int, components [4]C.float) {
+	switch c := c.(type) {
+	case nil:
+		return 0, components
+	case color.Gray:
+		components[0] = C.float(float64(c.Y) / 255)
+		return 1, components
+	case color.CMYK:
+		components[0] = C.float(float64(c.C) / 255)
+		components[1] = C.float(float64(c.M) / 255)
+		components[2] = C.float(float64(c.Y) / 255)
+		components[3] = C.float(float64(c.K) / 255)
+		return 4, components
+	default:
+		r, g, b, a := c.RGBA()
+		if a != 0 {
+			components[0] = C.float(float64(r) / float64(a))
+			components[1] = C.float(float64(g) / float64(a))
+			components[2] = C.float(float64(b) / float64(a))
+		}
+		return 3, components
+	}
+}
+
+// unscaleQuad converts a quad in rendered-image pixel space back into a MuPDF quad, the reverse of scaleQuad.
+func unscaleQuad(q Quad, scale float64) C.fz_quad {
+	return C.fz_quad{
+		ul: unscalePoint(q.UL, scale),
+		ur: unscalePoint(q.UR, scale),
+		ll: unscalePoint(q.LL, scale),
+		lr: unscalePoint(q.LR, scale),
+	}
+}
+
+// unscalePoints converts points in rendered-image pixel space back into MuPDF points, the reverse of scalePoints.
+func unscalePoints(points []Point, scale float64) []C.fz_point {
+	unscaled := make([]C.fz_point, len(points))
+	for i, p := range points {
+		unscaled[i] = unscalePoint(p, scale)
+	}
+	return unscaled
+}
+
+func unscalePoint(p Point, scale float64) C.fz_point {
+	return C.fz_point{x: C.float(p.X / scale), y: C.float(p.Y / scale)}
+}
+
+// firstOrNil returns a pointer to the first element of s, or nil if it is empty, for passing s to C.
+func firstOrNil[T any](s []T) *T {
+	if len(s) == 0 {
+		return nil
+	}
+	return &s[0]
+}
@@ -0,0 +1,206 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestEditAnnotations(t *testing.T) {
+	doc, err := pdf.New([]byte(annotationPDF), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+
+	highlight, err := page.CreateAnnotation(pdf.AnnotationHighlight, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if highlight.Type != pdf.AnnotationHighlight || highlight.ID == 0 {
+		t.Fatalf("expected a new Highlight with an ID, got %v with %d", highlight.Type, highlight.ID)
+	}
+	quad := pdf.Quad{
+		UL: pdf.Point{X: 10, Y: 100},
+		UR: pdf.Point{X: 60, Y: 100},
+		LL: pdf.Point{X: 10, Y: 110},
+		LR: pdf.Point{X: 60, Y: 110},
+	}
+	highlight.Quads = []pdf.Quad{quad}
+	highlight.Color = color.NRGBA{G: 255, A: 255}
+	highlight.Author = "Tester"
+	highlight.Contents = "Look here"
+	if highlight, err = page.UpdateAnnotation(highlight, 72); err != nil {
+		t.Fatal(err)
+	}
+	if len(highlight.Quads) != 1 || highlight.Quads[0] != quad {
+		t.Errorf("expected the quad %+v, got %+v", quad, highlight.Quads)
+	}
+	if highlight.Color != (color.NRGBA{G: 255, A: 255}) || highlight.Author != "Tester" ||
+		highlight.Contents != "Look here" {
+		t.Errorf("unexpected highlight: %+v", highlight)
+	}
+	if !highlight.Bounds.Overlaps(quad.Bounds()) {
+		t.Errorf("expected the highlight's bounds %v to cover its quad %v", highlight.Bounds, quad.Bounds())
+	}
+
+	// Colors whose types are not comparable are compared by their components instead.
+	highlight.Color = sliceColor{0, 0, 255}
+	if highlight, err = page.UpdateAnnotation(highlight, 72); err != nil {
+		t.Fatal(err)
+	}
+	if highlight.Color != (color.NRGBA{B: 255, A: 255}) {
+		t.Errorf("expected blue, got %v", highlight.Color)
+	}
+
+	ink, err := page.CreateAnnotation(pdf.AnnotationInk, 144)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ink.Ink = [][]pdf.Point{{{X: 40, Y: 40}, {X: 80, Y: 80}, {X: 120, Y: 40}}}
+	if ink, err = page.UpdateAnnotation(ink, 144); err != nil {
+		t.Fatal(err)
+	}
+	if len(ink.Ink) != 1 || len(ink.Ink[0]) != 3 || ink.Ink[0][1] != (pdf.Point{X: 80, Y: 80}) {
+		t.Errorf("expected one stroke of 3 points, got %+v", ink.Ink)
+	}
+
+	annotations, err := page.Annotations(72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := annotations[0]
+	comment.Contents = "Fixed"
+	before := time.Now().Add(-time.Minute)
+	if comment, err = page.UpdateAnnotation(comment, 72); err != nil {
+		t.Fatal(err)
+	}
+	if comment.Contents != "Fixed" || comment.Author != "Reviewer" || comment.Modified.Before(before) {
+		t.Errorf("expected the comment to be changed now, got %+v", comment)
+	}
+
+	if err = page.DeleteAnnotation(annotations[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = page.DeleteAnnotation(annotations[1].ID); !errors.Is(err, pdf.ErrAnnotationNotFound) {
+		t.Errorf("expected ErrAnnotationNotFound, got %v", err)
+	}
+	if _, err = page.CreateAnnotation(pdf.AnnotationLink, 72); !errors.Is(err, pdf.ErrInvalidAnnotationType) {
+		t.Errorf("expected ErrInvalidAnnotationType, got %v", err)
+	}
+	if annotations, err = page.Annotations(72); err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 4 {
+		t.Fatalf("expected 4 annotations after the edits, got %d", len(annotations))
+	}
+
+	// The changes survive being saved and opened again.
+	var buffer bytes.Buffer
+	if err = doc.Save(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := pdf.New(buffer.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saved.Release()
+	savedPage, err := saved.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer savedPage.Release()
+	reloaded, err := savedPage.Annotations(72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded) != len(annotations) {
+		t.Fatalf("expected %d annotations once saved, got %d", len(annotations), len(reloaded))
+	}
+	for i, a := range reloaded {
+		if want := annotations[i]; a.Type != want.Type || a.Contents != want.Contents || a.Author != want.Author ||
+			len(a.Quads) != len(want.Quads) || len(a.Ink) != len(want.Ink) {
+			t.Errorf("annotation %d: expected %+v, got %+v", i, want, a)
+		}
+	}
+}
+
+// sliceColor is an opaque RGB color held in a slice, which makes it a color.Color that cannot be compared with ==.
+type sliceColor []uint8
+
+func (c sliceColor) RGBA() (r, g, b, a uint32) {
+	return uint32(c[0]) * 0x101, uint32(c[1]) * 0x101, uint32(c[2]) * 0x101, 0xffff
+}
+
+func TestHighlightSearch(t *testing.T) {
+	doc, err := pdf.New(textPDF("BT /F1 12 Tf 10 150 Td (find me and find me) Tj 0 -14 Td (a wrapped) Tj 0 -14 Td (phrase) Tj ET"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	want, err := page.Render(context.Background(), 72, "find", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red := color.NRGBA{R: 255, A: 255}
+	added, err := doc.HighlightSearch("find", red, "Finder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Fatalf("expected 2 highlights, got %d", added)
+	}
+	if added, err = doc.HighlightSearch("wrapped phrase", nil, ""); err != nil || added != 1 {
+		t.Fatalf("expected 1 highlight, got %d (%v)", added, err)
+	}
+	if added, err = doc.HighlightSearch("missing", red, ""); err != nil || added != 0 {
+		t.Errorf("expected no highlights, got %d (%v)", added, err)
+	}
+
+	// The loaded page picks up the new annotations.
+	annotations, err := page.Annotations(72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 3 {
+		t.Fatalf("expected 3 annotations, got %d", len(annotations))
+	}
+	for i, a := range annotations[:2] {
+		if a.Type != pdf.AnnotationHighlight || a.Color != red || a.Author != "Finder" || len(a.Quads) != 1 {
+			t.Errorf("unexpected highlight %d: %+v", i, a)
+			continue
+		}
+		if got := a.Quads[0].Bounds(); got != want.SearchHits[i] {
+			t.Errorf("expected highlight %d over %v, got %v", i, want.SearchHits[i], got)
+		}
+	}
+	if wrapped := annotations[2]; len(wrapped.Quads) != 2 || wrapped.Color != (color.NRGBA{R: 255, G: 255, A: 255}) {
+		t.Errorf("expected a yellow highlight with 2 quads, got %+v", wrapped)
+	}
+
+	svgDoc, err := pdf.NewWithMIME([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`),
+		"image/svg+xml", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svgDoc.Release()
+	if _, err = svgDoc.HighlightSearch("find", red, ""); !errors.Is(err, pdf.ErrNotPDFData) {
+		t.Errorf("expected ErrNotPDFData, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"io"
+)
+
+// DefaultBandHeight is the number of rows in each band drawn by RenderBands when no band height is given.
+const DefaultBandHeight = 256
+
+// BandFunc receives each band of a page drawn by RenderBands, in order from the top of the page down. The bounds of
+// band are its position within the page, in the page's pixel space. The band's pixels are reused for the next band, so
+// they must not be retained once this returns. Returning an error stops the render, and RenderBands returns it.
+type BandFunc func(band image.Image) error
+
+// RenderBands renders the specified page as described by opts, which may be nil for the defaults, in horizontal bands
+// of up to bandHeight rows, passing each to fn as soon as it is drawn. Only one band is held in memory at a time, so the
+// page as a whole is not limited by OverallMaxPixels, only each band is; the band height is reduced as needed to stay
+// within it. A bandHeight of 0 or less means DefaultBandHeight. The returned RenderedPage has no image; its search hits
+// and links are in the pixel space of the whole page. If ctx is cancelled, the render is abandoned and ctx.Err() is
+// returned.
+func (d *Document) RenderBands(ctx context.Context, pageNumber, bandHeight int, opts *RenderOptions, fn BandFunc) (*RenderedPage, error) {
+	needle, searchOpts := opts.search()
+	s, err := newSearcher(needle, searchOpts)
+	if err != nil {
+		return nil, err
+	}
+	m, err := opts.transform()
+	if err != nil {
+		return nil, err
+	}
+	return d.runBands(ctx, bandHeight, opts, fn, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		return d.prepareRender(pageNumber, s, searchOpts.maxHits(), func(*C.fz_page) (Matrix, error) {
+			return m, nil
+		}, c)
+	})
+}
+
+// RenderBands renders the page in horizontal bands, as RenderBands does for a Document.
+func (p *Page) RenderBands(ctx context.Context, bandHeight int, opts *RenderOptions, fn BandFunc) (*RenderedPage, error) {
+	needle, searchOpts := opts.search()
+	s, err := newSearcher(needle, searchOpts)
+	if err != nil {
+		return nil, err
+	}
+	m, err := opts.transform()
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.runBands(ctx, bandHeight, opts, fn, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		p.owner.lock.Lock()
+		defer p.owner.lock.Unlock()
+		if err = p.releasedErr(); err != nil {
+			return nil, nil, err
+		}
+		return p.doc.prepareJob(p.displayList, p.links, p.number, m, s, searchOpts.maxHits(), c)
+	})
+}
+
+// WriteBands returns a BandFunc that writes the pixels of each band to w, row by row with no padding, in the layout of
+// the band's image type: 4 bytes per pixel for RGB and CMYK output and 1 for grayscale. Together, the bands make up the
+// raw pixels of the whole page, ready for an encoder that accepts them a row at a time.
+func WriteBands(w io.Writer) BandFunc {
+	return func(band image.Image) error {
+		pix, stride, n, err := imageSamples(band)
+		if err != nil {
+			return err
+		}
+		bounds := band.Bounds()
+		for y := range bounds.Dy() {
+			if _, err = w.Write(pix[y*stride : y*stride+bounds.Dx()*n]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// runBands calls prepare and then rasterizes the job it returns in bands, abandoning the render if ctx is cancelled.
+func (d *Document) runBands(ctx context.Context, bandHeight int, opts *RenderOptions, fn BandFunc, prepare func(c *cookie) (*RenderedPage, *renderJob, error)) (*RenderedPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	rendered, job, err := prepare(c)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	job.options = opts
+	if err = c.check(d.rasterizeBands(job, bandHeight, fn, c)); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// rasterizeBands renders the display list of a job from prepareRender in bands, as rasterize does for the whole page,
+// reusing one band's worth of pixels throughout. The job is finished with afterward.
+func (d *Document) rasterizeBands(job *renderJob, bandHeight int, fn BandFunc, c *cookie) error {
+	defer d.dropClone(job.ctx)
+	defer C.fz_drop_display_list(job.ctx, job.displayList)
+	job.options.applyAntiAliasing(job.ctx)
+	width := int64(job.bbox.x1) - int64(job.bbox.x0)
+	height := int64(job.bbox.y1) - int64(job.bbox.y0)
+	if width <= 0 || height <= 0 {
+		return ErrUnableToCreateImage
+	}
+	if width > int64(OverallMaxPixels) {
+		return ErrImageTooLarge
+	}
+	if bandHeight <= 0 {
+		bandHeight = DefaultBandHeight
+	}
+	rows := min(int64(bandHeight), height, int64(OverallMaxPixels)/width)
+	x0 := int(job.bbox.x0)
+	x1 := int(job.bbox.x1)
+	band := job.options.newImage(image.Rect(x0, 0, x1, int(rows)))
+	for y := int64(job.bbox.y0); y < int64(job.bbox.y1); y += rows {
+		bounds := image.Rect(x0, int(y), x1, int(min(y+rows, int64(job.bbox.y1))))
+		img := withBounds(band, bounds)
+		bbox := C.fz_irect{x0: job.bbox.x0, y0: C.int(bounds.Min.Y), x1: job.bbox.x1, y1: C.int(bounds.Max.Y)}
+		if err := drawDisplayList(job.ctx, job.displayList, job.ctm, bbox, img, job.options, c); err != nil {
+			return err
+		}
+		// A band cut short by cancellation must not be passed along.
+		if err := c.check(nil); err != nil {
+			return err
+		}
+		if err := fn(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withBounds returns an image that shares the pixels of img, which must be one from RenderOptions.newImage, starting
+// from its first pixel, but with the given bounds, which must be no larger than those of img.
+func withBounds(img image.Image, bounds image.Rectangle) image.Image {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return &image.NRGBA{Pix: img.Pix, Stride: img.Stride, Rect: bounds}
+	case *image.RGBA:
+		return &image.RGBA{Pix: img.Pix, Stride: img.Stride, Rect: bounds}
+	case *image.Gray:
+		return &image.Gray{Pix: img.Pix, Stride: img.Stride, Rect: bounds}
+	case *image.CMYK:
+		return &image.CMYK{Pix: img.Pix, Stride: img.Stride, Rect: bounds}
+	default:
+		return img
+	}
+}
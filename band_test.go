@@ -0,0 +1,95 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestRenderBands(t *testing.T) {
+	doc := loadTestDocument(t)
+	opts := &pdf.RenderOptions{DPI: 100, Needle: "GURPS"}
+	want, err := doc.RenderPageWithOptions(context.Background(), 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	write := pdf.WriteBands(&buffer)
+	next := 0
+	rendered, err := doc.RenderBands(context.Background(), 0, 50, opts, func(band image.Image) error {
+		bounds := band.Bounds()
+		if bounds.Min.Y != next || bounds.Dx() != want.Image.Rect.Dx() || bounds.Dy() > 50 {
+			t.Errorf("unexpected band bounds %v after row %d", bounds, next)
+		}
+		next = bounds.Max.Y
+		return write(band)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != want.Image.Rect.Max.Y {
+		t.Errorf("expected the bands to end at row %d, got %d", want.Image.Rect.Max.Y, next)
+	}
+	if !similarPixels(buffer.Bytes(), want.Image.Pix) {
+		t.Error("expected the bands to make up the same pixels as the whole page")
+	}
+	if rendered.Output != nil || len(rendered.SearchHits) != len(want.SearchHits) {
+		t.Errorf("expected no image and %d search hits, got %T and %d", len(want.SearchHits), rendered.Output,
+			len(rendered.SearchHits))
+	}
+
+	// A page too large to render at once can still be rendered in bands.
+	defer func(prev int) { pdf.OverallMaxPixels = prev }(pdf.OverallMaxPixels)
+	pdf.OverallMaxPixels = want.Image.Rect.Dx() * 10
+	if _, err = doc.RenderPageWithOptions(context.Background(), 0, opts); !errors.Is(err, pdf.ErrImageTooLarge) {
+		t.Fatalf("expected ErrImageTooLarge, got %v", err)
+	}
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	buffer.Reset()
+	if _, err = page.RenderBands(context.Background(), 0, opts, pdf.WriteBands(&buffer)); err != nil {
+		t.Fatal(err)
+	}
+	if !similarPixels(buffer.Bytes(), want.Image.Pix) {
+		t.Error("expected bands limited by OverallMaxPixels to make up the same pixels as the whole page")
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	if _, err = doc.RenderBands(context.Background(), 0, 0, nil, func(image.Image) error {
+		calls++
+		return stop
+	}); !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the callback's error after one band, got %v after %d", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = doc.RenderBands(ctx, 0, 0, nil, func(image.Image) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// similarPixels reports whether two sets of RGBA pixels are the same, allowing for the slight differences in
+// anti-aliasing where the bands meet, as the tile test does.
+func similarPixels(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	differences := 0
+	for i := 0; i+3 < len(a); i += 4 {
+		if absDiff(a[i], b[i]) > 2 || absDiff(a[i+1], b[i+1]) > 2 || absDiff(a[i+2], b[i+2]) > 2 ||
+			absDiff(a[i+3], b[i+3]) > 2 {
+			differences++
+		}
+	}
+	return differences <= len(a)/4/100
+}
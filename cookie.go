@@ -0,0 +1,136 @@
+package pdf
+
+/*
+#include <stdlib.h>
+#include <mupdf/fitz.h>
+*/
+import "C"
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// progressInterval is how often the progress of a MuPDF call is checked while it runs.
+const progressInterval = 50 * time.Millisecond
+
+// ProgressFunc receives the progress of the MuPDF calls made on behalf of a method given a context from WithProgress.
+// The work of a call happens in stages, such as interpreting a page and then drawing it; done counts up towards total
+// within a stage and starts over with the next one. total is -1 if it is not known.
+type ProgressFunc func(done, total int)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that carries fn, so that the methods which accept a context report their progress
+// to it while they work. fn is called from another goroutine while the underlying MuPDF call runs, and only when the
+// progress has changed since the last call. When RenderPages renders several pages at once, fn may be called
+// concurrently.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// cookie wraps the fz_cookie MuPDF polls during long-running calls, setting its abort flag once the context is
+// cancelled and passing its progress along to the context's ProgressFunc, if any.
+type cookie struct {
+	ctx       context.Context
+	ptr       *C.fz_cookie
+	progress  ProgressFunc
+	stop      func() bool
+	aborted   chan struct{}
+	done      chan struct{}
+	polled    chan struct{}
+	lastDone  int
+	lastTotal int
+}
+
+// newCookie returns a cookie for calls made on behalf of ctx. It must be released once those calls have returned.
+func newCookie(ctx context.Context) *cookie {
+	c := &cookie{ctx: ctx, lastTotal: -1}
+	c.progress, _ = ctx.Value(progressKey{}).(ProgressFunc)
+	if ctx.Done() == nil && c.progress == nil {
+		return c
+	}
+	// The cookie lives in C memory, as MuPDF reads and writes it while other goroutines do the same.
+	if c.ptr = (*C.fz_cookie)(C.calloc(1, C.sizeof_fz_cookie)); c.ptr == nil {
+		return c
+	}
+	c.ptr.progress_max = ^C.size_t(0)
+	if ctx.Done() != nil {
+		c.aborted = make(chan struct{})
+		c.stop = context.AfterFunc(ctx, func() {
+			atomic.StoreInt32((*int32)(unsafe.Pointer(&c.ptr.abort)), 1)
+			close(c.aborted)
+		})
+	}
+	if c.progress != nil {
+		c.done = make(chan struct{})
+		c.polled = make(chan struct{})
+		go c.poll()
+	}
+	return c
+}
+
+// fz returns the fz_cookie to pass to MuPDF, which is nil if there is nothing to watch for.
+func (c *cookie) fz() *C.fz_cookie {
+	if c == nil {
+		return nil
+	}
+	return c.ptr
+}
+
+// check returns ctx.Err() if the context has been cancelled, or err otherwise. A call whose context was cancelled may
+// have been aborted partway through, so whatever it produced must not be used.
+func (c *cookie) check(err error) error {
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// release stops watching the context and the progress, and frees the cookie.
+func (c *cookie) release() {
+	if c.ptr == nil {
+		return
+	}
+	if c.stop != nil && !c.stop() {
+		<-c.aborted
+	}
+	if c.done != nil {
+		close(c.done)
+		<-c.polled
+		c.report()
+	}
+	C.free(unsafe.Pointer(c.ptr))
+	c.ptr = nil
+}
+
+func (c *cookie) poll() {
+	defer close(c.polled)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.report()
+		}
+	}
+}
+
+// report passes the cookie's progress along to the ProgressFunc if it has changed since the last time.
+func (c *cookie) report() {
+	done := int(atomic.LoadInt32((*int32)(unsafe.Pointer(&c.ptr.progress))))
+	total := -1
+	if progressMax := atomic.LoadUintptr((*uintptr)(unsafe.Pointer(&c.ptr.progress_max))); progressMax <= math.MaxInt32 {
+		total = int(progressMax)
+	}
+	if done != c.lastDone || total != c.lastTotal {
+		c.lastDone = done
+		c.lastTotal = total
+		c.progress(done, total)
+	}
+}
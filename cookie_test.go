@@ -0,0 +1,108 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestCancellation(t *testing.T) {
+	doc := loadTestDocument(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := doc.RenderPageWithSearch(ctx, 0, 300, "GURPS", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from RenderPageWithSearch, got %v", err)
+	}
+	if _, err := doc.RenderPageForSizeWithSearch(ctx, 0, 800, 800, "", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from RenderPageForSizeWithSearch, got %v", err)
+	}
+	if _, err := doc.PageText(ctx, 0, 72, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from PageText, got %v", err)
+	}
+	if _, err := doc.Text(ctx, 0, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from Text, got %v", err)
+	}
+	if _, err := doc.SelectText(ctx, 0, image.Point{}, image.Pt(100, 100), 72, pdf.SelectChars); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from SelectText, got %v", err)
+	}
+	if _, err := doc.HitTest(ctx, 0, 72, image.Point{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from HitTest, got %v", err)
+	}
+
+	// The document must still be usable afterward.
+	if _, err := doc.RenderPageWithSearch(context.Background(), 0, 72, "", nil); err != nil {
+		t.Errorf("expected the document to still render after a cancelled call, got %v", err)
+	}
+}
+
+func TestCancellationWhileRendering(t *testing.T) {
+	doc := loadTestDocument(t)
+	pages := make([]int, doc.PageCount())
+	for i := range pages {
+		pages[i] = i
+	}
+	var once sync.Once
+	var cancelledAt time.Time
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := func() {
+		once.Do(func() {
+			cancelledAt = time.Now()
+			cancel()
+		})
+	}
+	// Cancel as soon as the first page reports progress or is done, whichever comes first.
+	ctx = pdf.WithProgress(ctx, func(_, _ int) { stop() })
+	var rendered int
+	var lastErr error
+	for page, err := range doc.RenderPages(ctx, pages, &pdf.RenderOptions{DPI: 300}, 1) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if page != nil {
+			rendered++
+		}
+		stop()
+	}
+	stop() // Synchronizes with the cancellation, so cancelledAt can be read.
+	if !errors.Is(lastErr, context.Canceled) {
+		t.Fatalf("expected context.Canceled from RenderPages, got %v", lastErr)
+	}
+	if rendered >= len(pages) && len(pages) > 1 {
+		t.Errorf("expected the cancellation to stop the rendering early, got all %d pages", rendered)
+	}
+	if elapsed := time.Since(cancelledAt); elapsed > 5*time.Second {
+		t.Errorf("expected RenderPages to stop promptly once cancelled, took %v", elapsed)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	doc := loadTestDocument(t)
+	var lock sync.Mutex
+	var calls, lastDone, lastTotal int
+	ctx := pdf.WithProgress(context.Background(), func(done, total int) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		lastDone = done
+		lastTotal = total
+	})
+	if _, err := doc.RenderPageWithSearch(ctx, 0, 150, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if calls == 0 {
+		t.Fatal("expected at least one progress report")
+	}
+	// The last report is for drawing the page, whose total is known once it starts.
+	if lastTotal <= 0 || lastDone < 0 || lastDone > lastTotal {
+		t.Errorf("expected final progress within a known total, got %d of %d", lastDone, lastTotal)
+	}
+}
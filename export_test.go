@@ -0,0 +1,16 @@
+package pdf
+
+// SearchContextOfUnlocatedMatch returns the Before, Match, and After text that SearchResults gives for a match MuPDF
+// reported on a page holding text, but whose quads do not cover any of the page's characters.
+func SearchContextOfUnlocatedMatch(text string, contextChars int) (before, match, after string) {
+	t := &searchText{text: text}
+	for i, ch := range text {
+		t.chars = append(t.chars, searchChar{offset: i, ch: ch})
+	}
+	var unlocated textMatch
+	return t.context(t.locate(sliceOf(unlocated.quads))[0], contextChars)
+}
+
+func sliceOf[T any](v T) []T {
+	return []T{v}
+}
@@ -0,0 +1,259 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+// Finds the topmost form widget, or failing that the topmost annotation, on the page whose bounds contain pt. Returns 1
+// for a widget and 2 for an annotation, filling in its bounds, its type, and, for a widget, its newly allocated field
+// name. Returns 0 if nothing contains pt, the page is not a PDF page, or it threw. The caller must free *name.
+int wrapped_hit_test_annots(fz_context *ctx, fz_page *fzpage, fz_point pt, fz_rect *bounds, const char **type, char **name) {
+	pdf_page *page = pdf_page_from_fz_page(ctx, fzpage);
+	pdf_annot *annot;
+	pdf_annot *found = NULL;
+	int kind = 0;
+	*type = NULL;
+	*name = NULL;
+	if (page == NULL) {
+		return 0;
+	}
+	fz_var(kind);
+	fz_try(ctx) {
+		for (annot = pdf_first_widget(ctx, page); annot != NULL; annot = pdf_next_widget(ctx, annot)) {
+			if (fz_is_point_inside_rect(pt, pdf_bound_widget(ctx, annot))) {
+				found = annot;
+			}
+		}
+		if (found != NULL) {
+			kind = 1;
+			*bounds = pdf_bound_widget(ctx, found);
+			*type = pdf_field_type_string(ctx, pdf_annot_obj(ctx, found));
+			*name = pdf_load_field_name(ctx, pdf_annot_obj(ctx, found));
+		} else {
+			for (annot = pdf_first_annot(ctx, page); annot != NULL; annot = pdf_next_annot(ctx, annot)) {
+				if (fz_is_point_inside_rect(pt, pdf_bound_annot(ctx, annot))) {
+					found = annot;
+				}
+			}
+			if (found != NULL) {
+				kind = 2;
+				*bounds = pdf_bound_annot(ctx, found);
+				*type = pdf_string_from_annot_type(ctx, pdf_annot_type(ctx, found));
+			}
+		}
+	}
+	fz_catch(ctx) {
+		kind = 0;
+	}
+	return kind;
+}
+
+// Defined in text.go.
+fz_stext_line *stext_block_first_line(fz_stext_block *block);
+fz_stext_block *stext_block_first_child(fz_stext_block *block);
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"unicode"
+	"unsafe"
+)
+
+// HitKind identifies what lies under a point on a page.
+type HitKind byte
+
+// Possible values for HitKind.
+const (
+	HitNone HitKind = iota
+	HitLink
+	HitWidget
+	HitAnnotation
+	HitText
+	HitImage
+)
+
+// HitTestResult describes what lies under a point on a page.
+type HitTestResult struct {
+	// Link is the link under the point, for HitLink.
+	Link *PageLink
+	// Type is the field type of the form widget under the point, such as "Text" or "CheckBox", for HitWidget, or the
+	// subtype of the annotation under the point, such as "Highlight", for HitAnnotation.
+	Type string
+	// Name is the fully qualified field name of the form widget under the point, for HitWidget.
+	Name string
+	// Word is the word containing the character under the point, for HitText.
+	Word string
+	// Bounds is the area covered by whatever is under the point, in rendered-image pixel space. For HitText, it covers
+	// the word.
+	Bounds image.Rectangle
+	// Char is the area covered by the character under the point, in rendered-image pixel space, for HitText.
+	Char Quad
+	// Rune is the character under the point, for HitText.
+	Rune rune
+	Kind HitKind
+}
+
+// HitTest returns what lies under the point on the specified page, with the point in the pixel space of the page
+// rendered at the requested dpi, as used by RenderPage. When several things overlap, the first of a link, a form widget,
+// an annotation, text, or an image is reported. If nothing is under the point, the result has a Kind of HitNone. If ctx
+// is cancelled, the text extraction is abandoned and ctx.Err() is returned.
+func (d *Document) HitTest(ctx context.Context, pageNumber, dpi int, pt image.Point) (*HitTestResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	page, err := d.loadPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer C.fz_drop_page(d.ctx, page)
+	scale := dpiToScale(dpi)
+	for _, link := range d.loadLinks(page, scale) {
+		if pt.In(link.Bounds) {
+			return &HitTestResult{
+				Link:   link,
+				Bounds: link.Bounds,
+				Kind:   HitLink,
+			}, nil
+		}
+	}
+	pagePt := pointToPage(pt, scale)
+	if result := d.hitTestAnnots(page, pagePt, scale); result != nil {
+		return result, nil
+	}
+	displayList := d.newDisplayList(page, c)
+	if displayList == nil {
+		return nil, c.check(ErrUnableToExtractText)
+	}
+	defer C.fz_drop_display_list(d.ctx, displayList)
+	stext := d.newStructuredText(displayList, C.FZ_STEXT_PRESERVE_IMAGES, c)
+	if stext == nil {
+		return nil, c.check(ErrUnableToExtractText)
+	}
+	defer C.fz_drop_stext_page(d.ctx, stext)
+	if err = c.check(nil); err != nil {
+		return nil, err
+	}
+	if result := hitTestText(stext.first_block, pagePt, scale); result != nil {
+		return result, nil
+	}
+	if result := hitTestImages(stext.first_block, pagePt, scale); result != nil {
+		return result, nil
+	}
+	return &HitTestResult{Kind: HitNone}, nil
+}
+
+// hitTestAnnots returns the form widget or annotation under the point, or nil if there isn't one. The caller must hold
+// d.lock.
+func (d *Document) hitTestAnnots(page *C.fz_page, pt C.fz_point, scale float64) *HitTestResult {
+	var bounds C.fz_rect
+	var typeName *C.char
+	var name *C.char
+	kind := C.wrapped_hit_test_annots(d.ctx, page, pt, &bounds, &typeName, &name)
+	if name != nil {
+		defer C.fz_free(d.ctx, unsafe.Pointer(name))
+	}
+	var result *HitTestResult
+	switch kind {
+	case 1:
+		result = &HitTestResult{Kind: HitWidget, Name: sanitizeString(name)}
+	case 2:
+		result = &HitTestResult{Kind: HitAnnotation}
+	default:
+		return nil
+	}
+	if typeName != nil {
+		result.Type = sanitizeString(typeName)
+	}
+	result.Bounds = rectToImage(bounds, scale)
+	return result
+}
+
+// hitTestText returns the character under the point, along with the word containing it, or nil if there isn't one.
+func hitTestText(block *C.fz_stext_block, pt C.fz_point, scale float64) *HitTestResult {
+	for ; block != nil; block = block.next {
+		switch block._type {
+		case C.FZ_STEXT_BLOCK_TEXT:
+			if C.fz_is_point_inside_rect(pt, block.bbox) == 0 {
+				continue
+			}
+			for line := C.stext_block_first_line(block); line != nil; line = line.next {
+				if C.fz_is_point_inside_rect(pt, line.bbox) == 0 {
+					continue
+				}
+				var chars []*C.fz_stext_char
+				hit := -1
+				for ch := line.first_char; ch != nil; ch = ch.next {
+					if hit < 0 && C.fz_is_point_inside_quad(pt, ch.quad) != 0 {
+						hit = len(chars)
+					}
+					chars = append(chars, ch)
+				}
+				if hit >= 0 && !unicode.IsSpace(rune(chars[hit].c)) {
+					return newTextHit(chars, hit, scale)
+				}
+			}
+		case C.FZ_STEXT_BLOCK_STRUCT:
+			if result := hitTestText(C.stext_block_first_child(block), pt, scale); result != nil {
+				return result
+			}
+		default:
+		}
+	}
+	return nil
+}
+
+// newTextHit returns the result for the character at index hit within the characters of a line.
+func newTextHit(chars []*C.fz_stext_char, hit int, scale float64) *HitTestResult {
+	first := hit
+	for first > 0 && !unicode.IsSpace(rune(chars[first-1].c)) {
+		first--
+	}
+	last := hit + 1
+	for last < len(chars) && !unicode.IsSpace(rune(chars[last].c)) {
+		last++
+	}
+	runes := make([]rune, 0, last-first)
+	bounds := C.fz_empty_rect
+	for _, ch := range chars[first:last] {
+		runes = append(runes, rune(ch.c))
+		bounds = C.fz_union_rect(bounds, C.fz_rect_from_quad(ch.quad))
+	}
+	return &HitTestResult{
+		Word:   filterUnsafeRunes(string(runes), nil),
+		Bounds: rectToImage(bounds, scale),
+		Char:   scaleQuad(chars[hit].quad, scale),
+		Rune:   rune(chars[hit].c),
+		Kind:   HitText,
+	}
+}
+
+// hitTestImages returns the topmost image under the point, or nil if there isn't one.
+func hitTestImages(block *C.fz_stext_block, pt C.fz_point, scale float64) *HitTestResult {
+	var result *HitTestResult
+	for ; block != nil; block = block.next {
+		switch block._type {
+		case C.FZ_STEXT_BLOCK_IMAGE:
+			if C.fz_is_point_inside_rect(pt, block.bbox) != 0 {
+				result = &HitTestResult{
+					Bounds: rectToImage(block.bbox, scale),
+					Kind:   HitImage,
+				}
+			}
+		case C.FZ_STEXT_BLOCK_STRUCT:
+			if child := hitTestImages(C.stext_block_first_child(block), pt, scale); child != nil {
+				result = child
+			}
+		default:
+		}
+	}
+	return result
+}
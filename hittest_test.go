@@ -0,0 +1,118 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestHitTest(t *testing.T) {
+	doc, err := pdf.New(annotatedPDF(helloWorld,
+		"<< /Type /Annot /Subtype /Square /Rect [100 20 150 60] >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Tx /T (name) /Rect [20 160 120 180] >>",
+	), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+
+	// At 144 dpi, "Hello World" sits on a baseline at y=100, with "Hello" running from x=20 to roughly x=75 and "World"
+	// from roughly x=81 to x=144. The square annotation covers (200, 280)-(300, 360) and the text field covers
+	// (40, 40)-(240, 80).
+	for _, one := range []struct {
+		word string
+		typ  string
+		name string
+		pt   image.Point
+		kind pdf.HitKind
+		ch   rune
+	}{
+		{pt: image.Pt(28, 92), kind: pdf.HitText, word: "Hello", ch: 'H'},
+		{pt: image.Pt(100, 92), kind: pdf.HitText, word: "World", ch: 'W'},
+		{pt: image.Pt(250, 320), kind: pdf.HitAnnotation, typ: "Square"},
+		{pt: image.Pt(100, 60), kind: pdf.HitWidget, typ: "Text", name: "name"},
+		{pt: image.Pt(350, 200), kind: pdf.HitNone},
+	} {
+		result, hitErr := doc.HitTest(context.Background(), 0, 144, one.pt)
+		if hitErr != nil {
+			t.Fatal(hitErr)
+		}
+		if result.Kind != one.kind {
+			t.Errorf("expected kind %d at %v, got %d", one.kind, one.pt, result.Kind)
+			continue
+		}
+		if result.Word != one.word || result.Type != one.typ || result.Name != one.name {
+			t.Errorf("expected word %q, type %q, and name %q at %v, got %q, %q, and %q", one.word, one.typ, one.name,
+				one.pt, result.Word, result.Type, result.Name)
+		}
+		if one.kind == pdf.HitText && result.Rune != one.ch {
+			t.Errorf("expected rune %q at %v, got %q", one.ch, one.pt, result.Rune)
+		}
+		if one.kind != pdf.HitNone && !one.pt.In(result.Bounds) {
+			t.Errorf("expected the bounds %v to contain %v", result.Bounds, one.pt)
+		}
+	}
+
+	if _, err = doc.HitTest(context.Background(), 1, 144, image.Point{}); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+	doc.Release()
+	if _, err = doc.HitTest(context.Background(), 0, 144, image.Point{}); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from HitTest after release, got %v", err)
+	}
+}
+
+func TestHitTestLinks(t *testing.T) {
+	doc := loadTestDocument(t)
+	for pageNumber := range doc.PageCount() {
+		page, err := doc.RenderPage(pageNumber, 72, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Links) == 0 {
+			continue
+		}
+		link := page.Links[0]
+		pt := link.Bounds.Min.Add(link.Bounds.Max).Div(2)
+		var result *pdf.HitTestResult
+		if result, err = doc.HitTest(context.Background(), pageNumber, 72, pt); err != nil {
+			t.Fatal(err)
+		}
+		if result.Kind != pdf.HitLink || result.Link == nil {
+			t.Fatalf("expected a link at %v on page %d, got kind %d", pt, pageNumber, result.Kind)
+		}
+		if result.Link.URI != link.URI || result.Link.PageNumber != link.PageNumber {
+			t.Errorf("expected link %+v at %v on page %d, got %+v", link, pt, pageNumber, result.Link)
+		}
+		return
+	}
+	t.Fatal("expected the test document to have links")
+}
+
+func TestHitTestImage(t *testing.T) {
+	// A 1x1 gray inline image scaled to cover (100, 20)-(150, 70) in PDF space, which at 72 dpi is (100, 130)-(150, 180).
+	doc, err := pdf.New(textPDF("q 50 0 0 50 100 20 cm BI /W 1 /H 1 /CS /G /BPC 8 /F /AHx ID 80> EI Q"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	result, err := doc.HitTest(context.Background(), 0, 72, image.Pt(125, 155))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Kind != pdf.HitImage {
+		t.Fatalf("expected an image, got kind %d", result.Kind)
+	}
+	if want := image.Rect(100, 130, 150, 180); result.Bounds != want {
+		t.Errorf("expected the image bounds %v, got %v", want, result.Bounds)
+	}
+	if result, err = doc.HitTest(context.Background(), 0, 72, image.Pt(50, 50)); err != nil {
+		t.Fatal(err)
+	}
+	if result.Kind != pdf.HitNone {
+		t.Errorf("expected nothing outside the image, got kind %d", result.Kind)
+	}
+}
@@ -0,0 +1,69 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+// Fills buf with the label of the page, which is its one-based number if the document does not define labels for it.
+// Returns 0 if it threw.
+int wrapped_pdf_page_label(fz_context *ctx, fz_document *doc, int page, char *buf, int size) {
+	pdf_document *pdf = pdf_specifics(ctx, doc);
+	int ok = 0;
+	if (pdf == NULL) {
+		fz_snprintf(buf, (size_t)size, "%d", page + 1);
+		return 1;
+	}
+	fz_var(ok);
+	fz_try(ctx) {
+		pdf_page_label(ctx, pdf, page, buf, (size_t)size);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+*/
+import "C"
+
+// PageLabel returns the label of the specified page, such as "iv" or "A-3", as defined by the document's page labels.
+// Pages without a label defined for them are labeled with their one-based page number.
+func (d *Document) PageLabel(pageNumber int) (string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return "", ErrDocumentReleased
+	}
+	if pageNumber < 0 || pageNumber >= d.pageCount() {
+		return "", ErrInvalidPageNumber
+	}
+	return d.pageLabel(pageNumber), nil
+}
+
+// PageFromLabel returns the number of the first page whose label, as returned by PageLabel, matches label exactly.
+func (d *Document) PageFromLabel(label string) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return -1, ErrDocumentReleased
+	}
+	for pageNumber := range d.pageCount() {
+		if d.pageLabel(pageNumber) == label {
+			return pageNumber, nil
+		}
+	}
+	return -1, ErrPageLabelNotFound
+}
+
+// pageLabel returns the sanitized label of the page, or an empty string if the page number is invalid or the label
+// cannot be determined. The caller must hold d.lock and must have checked that the document has not been released.
+func (d *Document) pageLabel(pageNumber int) string {
+	if pageNumber < 0 {
+		return ""
+	}
+	var buf [256]C.char
+	if C.wrapped_pdf_page_label(d.ctx, d.doc, C.int(pageNumber), &buf[0], C.int(len(buf))) == 0 {
+		return ""
+	}
+	return sanitizeString(&buf[0])
+}
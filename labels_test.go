@@ -0,0 +1,96 @@
+package pdf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+// labelsPDF has four pages labeled "i", "ii", "A-3", and "A-4", a link on the first page to the second, and an outline
+// entry for the last.
+const labelsPDF = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R /Outlines 7 0 R
+/PageLabels << /Nums [0 << /S /r >> 2 << /S /D /P (A-) /St 3 >>] >> >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R 6 0 R] /Count 4 /MediaBox [0 0 200 200] >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /Annots [9 0 R] >>
+endobj
+4 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+5 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+6 0 obj
+<< /Type /Page /Parent 2 0 R >>
+endobj
+7 0 obj
+<< /Type /Outlines /First 8 0 R /Last 8 0 R /Count 1 >>
+endobj
+8 0 obj
+<< /Title (Appendix) /Parent 7 0 R /Dest [6 0 R /Fit] >>
+endobj
+9 0 obj
+<< /Type /Annot /Subtype /Link /Rect [10 10 100 100] /Border [0 0 0] /Dest [4 0 R /Fit] >>
+endobj
+trailer
+<< /Root 1 0 R /Size 10 >>
+startxref
+0
+%%EOF
+`
+
+func TestPageLabels(t *testing.T) {
+	doc, err := pdf.New([]byte(labelsPDF), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	for i, want := range []string{"i", "ii", "A-3", "A-4"} {
+		label, labelErr := doc.PageLabel(i)
+		if labelErr != nil {
+			t.Fatal(labelErr)
+		}
+		if label != want {
+			t.Errorf("expected page %d to be labeled %q, got %q", i, want, label)
+		}
+		pageNumber, labelErr := doc.PageFromLabel(want)
+		if labelErr != nil {
+			t.Fatal(labelErr)
+		}
+		if pageNumber != i {
+			t.Errorf("expected label %q to map to page %d, got %d", want, i, pageNumber)
+		}
+	}
+	if _, err = doc.PageLabel(4); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+	if _, err = doc.PageFromLabel("iii"); !errors.Is(err, pdf.ErrPageLabelNotFound) {
+		t.Errorf("expected ErrPageLabelNotFound for an unknown label, got %v", err)
+	}
+
+	toc := doc.TableOfContents(72)
+	if len(toc) != 1 || toc[0].PageNumber != 3 || toc[0].PageLabel != "A-4" {
+		t.Errorf("expected a single TOC entry for page 3 labeled %q, got %+v", "A-4", toc)
+	}
+	page, err := doc.RenderPage(0, 72, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Links) != 1 || page.Links[0].PageNumber != 1 || page.Links[0].PageLabel != "ii" {
+		t.Errorf("expected a single link to page 1 labeled %q, got %+v", "ii", page.Links)
+	}
+
+	doc.Release()
+	if _, err = doc.PageLabel(0); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from PageLabel after release, got %v", err)
+	}
+	if _, err = doc.PageFromLabel("i"); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from PageFromLabel after release, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package pdf
+
+/*
+#include <stdlib.h>
+#include <mupdf/fitz.h>
+
+// Returns 1 on success, 0 if it threw.
+int wrapped_fz_layout_document(fz_context *ctx, fz_document *doc, float w, float h, float em) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		fz_layout_document(ctx, doc, w, h, em);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Returns the result of fz_is_document_reflowable, or 0 if it threw.
+int wrapped_fz_is_document_reflowable(fz_context *ctx, fz_document *doc) {
+	int reflowable = 0;
+	fz_var(reflowable);
+	fz_try(ctx) {
+		reflowable = fz_is_document_reflowable(ctx, doc);
+	}
+	fz_catch(ctx) {
+		reflowable = 0;
+	}
+	return reflowable;
+}
+
+// Returns a bookmark for the page, storing 1 in ok, or stores 0 in ok if it threw.
+fz_bookmark wrapped_fz_make_bookmark(fz_context *ctx, fz_document *doc, int number, int *ok) {
+	fz_bookmark mark = 0;
+	fz_var(mark);
+	*ok = 0;
+	fz_try(ctx) {
+		mark = fz_make_bookmark(ctx, doc, fz_location_from_page_number(ctx, doc, number));
+		*ok = 1;
+	}
+	fz_catch(ctx) {
+		mark = 0;
+	}
+	return mark;
+}
+
+// Returns the page number the bookmark refers to in the current layout, or -1 if it cannot be found or it threw.
+int wrapped_fz_lookup_bookmark(fz_context *ctx, fz_document *doc, fz_bookmark mark) {
+	int number = -1;
+	fz_var(number);
+	fz_try(ctx) {
+		number = fz_page_number_from_location(ctx, doc, fz_lookup_bookmark(ctx, doc, mark));
+	}
+	fz_catch(ctx) {
+		number = -1;
+	}
+	return number;
+}
+
+// Returns 1 on success, 0 if it threw.
+int wrapped_fz_set_user_css(fz_context *ctx, const char *css, int use_document_css) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		fz_set_user_css(ctx, css);
+		fz_set_use_document_css(ctx, use_document_css);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+*/
+import "C"
+
+import (
+	"math"
+	"unsafe"
+)
+
+// Bookmark identifies a position within a reflowable document that survives a change to its layout. Bookmarks are only
+// meaningful to the document that made them.
+type Bookmark int64
+
+// IsReflowable returns true if the document's pages are laid out by MuPDF, as they are for formats such as EPUB, FB2,
+// and HTML, and so can be changed with Layout. Returns false if the document has been released.
+func (d *Document) IsReflowable() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return false
+	}
+	return C.wrapped_fz_is_document_reflowable(d.ctx, d.doc) != 0
+}
+
+// Layout lays out a reflowable document onto pages of the given width and height, in points, with text at the given
+// default font size, also in points. This usually changes the number of pages, so page numbers from before the layout
+// should be converted with MakeBookmark beforehand and PageFromBookmark afterward. Layout has no effect on documents
+// that are not reflowable.
+func (d *Document) Layout(width, height, fontSize float64) error {
+	if !(width > 0 && height > 0 && fontSize > 0) || width > math.MaxFloat32 || height > math.MaxFloat32 ||
+		fontSize > math.MaxFloat32 {
+		return ErrInvalidPageSize
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return ErrDocumentReleased
+	}
+	if C.wrapped_fz_is_document_reflowable(d.ctx, d.doc) == 0 {
+		return nil
+	}
+	pages := d.dropPages()
+	defer d.reloadPages(pages)
+	size := &layoutSize{width: width, height: height, fontSize: fontSize}
+	if !d.layOut(size) {
+		return ErrUnableToLayout
+	}
+	d.layout = size
+	return nil
+}
+
+// SetUserCSS replaces the style sheet applied to this reflowable document on top of its own styles, and controls
+// whether its own style sheets are used, as UserCSS and UseDocumentCSS do for documents as they are opened. As the
+// styles are applied when a document is opened, the document is opened again from its data and laid out as it was
+// last laid out by Layout. This usually changes the number of pages, so page numbers from before the change should be
+// converted with MakeBookmark beforehand and PageFromBookmark afterward. Loaded pages are loaded again in the new
+// layout; those that no longer exist are released. SetUserCSS has no effect on documents that are not reflowable.
+func (d *Document) SetUserCSS(css string, useDocumentCSS bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return ErrDocumentReleased
+	}
+	if C.wrapped_fz_is_document_reflowable(d.ctx, d.doc) == 0 {
+		return nil
+	}
+	if !d.setUserCSS(css, useDocumentCSS) {
+		return ErrUnableToLayout
+	}
+	pages := d.dropPages()
+	defer d.reloadPages(pages)
+	return d.reopen()
+}
+
+// MakeBookmark returns a bookmark for the start of the specified page, which can be turned back into a page number with
+// PageFromBookmark after the document has been laid out again.
+func (d *Document) MakeBookmark(pageNumber int) (Bookmark, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return 0, ErrDocumentReleased
+	}
+	if pageNumber < 0 || pageNumber >= d.pageCount() {
+		return 0, ErrInvalidPageNumber
+	}
+	var ok C.int
+	mark := C.wrapped_fz_make_bookmark(d.ctx, d.doc, C.int(pageNumber), &ok)
+	if ok == 0 {
+		return 0, ErrInvalidBookmark
+	}
+	return Bookmark(mark), nil
+}
+
+// PageFromBookmark returns the number of the page that holds the position marked by the bookmark in the document's
+// current layout.
+func (d *Document) PageFromBookmark(mark Bookmark) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return -1, ErrDocumentReleased
+	}
+	pageNumber := int(C.wrapped_fz_lookup_bookmark(d.ctx, d.doc, C.fz_bookmark(mark)))
+	if pageNumber < 0 || pageNumber >= d.pageCount() {
+		return -1, ErrInvalidBookmark
+	}
+	return pageNumber, nil
+}
+
+// layoutSize holds the arguments of a call to Layout.
+type layoutSize struct {
+	width    float64
+	height   float64
+	fontSize float64
+}
+
+// applyUserCSS installs UserCSS and UseDocumentCSS in the document's context, returning false if that fails.
+func (d *document) applyUserCSS() bool {
+	if UserCSS == "" && UseDocumentCSS {
+		return true
+	}
+	return d.setUserCSS(UserCSS, UseDocumentCSS)
+}
+
+// setUserCSS installs the style sheet in the document's context, returning false if that fails. It only affects the
+// documents opened in the context afterward.
+func (d *document) setUserCSS(css string, useDocumentCSS bool) bool {
+	cCSS := C.CString(css)
+	defer C.free(unsafe.Pointer(cCSS))
+	use := C.int(0)
+	if useDocumentCSS {
+		use = 1
+	}
+	return C.wrapped_fz_set_user_css(d.ctx, cCSS, use) != 0
+}
+
+// layOut lays out the document to size, returning false if that fails. The caller must hold d.lock.
+func (d *document) layOut(size *layoutSize) bool {
+	return C.wrapped_fz_layout_document(d.ctx, d.doc, C.float(size.width), C.float(size.height),
+		C.float(size.fontSize)) != 0
+}
+
+// dropPages drops the pages and display lists held by the loaded pages, before the layout of the document changes, and
+// returns the loaded pages so that reloadPages can load them again afterward. The caller must hold d.lock.
+func (d *Document) dropPages() []*loadedPage {
+	pages := make([]*loadedPage, 0, len(d.pages))
+	for lp := range d.pages {
+		pages = append(pages, lp)
+	}
+	for _, lp := range pages {
+		lp.drop()
+	}
+	return pages
+}
+
+// reloadPages loads the pages dropped by dropPages again in the document's current layout, along with their display
+// lists and links. Those past the end of the document, or that cannot be loaded, are left released. The caller must
+// hold d.lock.
+func (d *Document) reloadPages(pages []*loadedPage) {
+	for _, lp := range pages {
+		page, err := d.loadPage(lp.number)
+		if err != nil {
+			continue
+		}
+		displayList := d.newDisplayList(page, nil)
+		if displayList == nil {
+			C.fz_drop_page(d.ctx, page)
+			continue
+		}
+		lp.page = page
+		lp.displayList = displayList
+		lp.links = d.resolveLinks(page)
+		d.pages[lp] = struct{}{}
+	}
+}
@@ -0,0 +1,162 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+// loremHTML is long enough to need several pages at small page sizes.
+var loremHTML = "<html><body>" + strings.Repeat("<p>Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do "+
+	"eiusmod tempor incididunt ut labore et dolore magna aliqua.</p>", 40) + "</body></html>"
+
+func TestLayout(t *testing.T) {
+	doc, err := pdf.NewWithMIME([]byte(loremHTML), "text/html", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	if !doc.IsReflowable() {
+		t.Fatal("expected an HTML document to be reflowable")
+	}
+	if err = doc.Layout(200, 200, 12); err != nil {
+		t.Fatal(err)
+	}
+	small := doc.PageCount()
+	if small < 2 {
+		t.Fatalf("expected several pages with a small layout, got %d", small)
+	}
+	mark, err := doc.MakeBookmark(small - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = doc.Layout(600, 800, 12); err != nil {
+		t.Fatal(err)
+	}
+	large := doc.PageCount()
+	if large >= small {
+		t.Errorf("expected fewer pages with a larger layout, got %d then %d", small, large)
+	}
+	pageNumber, err := doc.PageFromBookmark(mark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pageNumber != large-1 {
+		t.Errorf("expected the bookmark for the last page to map to the last page, %d, got %d", large-1, pageNumber)
+	}
+	page, err := doc.RenderPage(0, 72, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := page.Image.Bounds(); b.Dx() != 600 || b.Dy() != 800 {
+		t.Errorf("expected a 600×800 page, got %v", b)
+	}
+
+	if err = doc.Layout(0, 800, 12); !errors.Is(err, pdf.ErrInvalidPageSize) {
+		t.Errorf("expected ErrInvalidPageSize for a zero width, got %v", err)
+	}
+	if _, err = doc.MakeBookmark(large); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+	doc.Release()
+	if err = doc.Layout(600, 800, 12); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from Layout after release, got %v", err)
+	}
+}
+
+func TestLayoutNotReflowable(t *testing.T) {
+	doc := loadTestDocument(t)
+	if doc.IsReflowable() {
+		t.Error("expected a PDF not to be reflowable")
+	}
+	count := doc.PageCount()
+	if err := doc.Layout(200, 200, 12); err != nil {
+		t.Fatal(err)
+	}
+	if doc.PageCount() != count {
+		t.Errorf("expected Layout to leave the PDF's %d pages alone, got %d", count, doc.PageCount())
+	}
+}
+
+func TestUserCSS(t *testing.T) {
+	pageCount := func() int {
+		doc, err := pdf.NewWithMIME([]byte(loremHTML), "text/html", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer doc.Release()
+		if err = doc.Layout(300, 300, 12); err != nil {
+			t.Fatal(err)
+		}
+		return doc.PageCount()
+	}
+	plain := pageCount()
+	defer func(prev string) { pdf.UserCSS = prev }(pdf.UserCSS)
+	pdf.UserCSS = "p { font-size: 36pt; }"
+	if styled := pageCount(); styled <= plain {
+		t.Errorf("expected larger text from the user style sheet to need more than %d pages, got %d", plain, styled)
+	}
+}
+
+func TestSetUserCSS(t *testing.T) {
+	doc, err := pdf.NewWithMIME([]byte(loremHTML), "text/html", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	if err = doc.Layout(300, 300, 12); err != nil {
+		t.Fatal(err)
+	}
+	plain := doc.PageCount()
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	before, err := page.Text(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = doc.SetUserCSS("p { font-size: 36pt; }", true); err != nil {
+		t.Fatal(err)
+	}
+	if styled := doc.PageCount(); styled <= plain {
+		t.Errorf("expected larger text from the style sheet to need more than %d pages, got %d", plain, styled)
+	}
+	// The loaded page shows the new layout, keeping the page size.
+	after, err := page.Text(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) >= len(before) {
+		t.Errorf("expected less text on the first page with larger text, got %d characters then %d", len(before),
+			len(after))
+	}
+	rendered, err := page.Render(context.Background(), 72, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := rendered.Image.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+		t.Errorf("expected a 300×300 page, got %v", b)
+	}
+
+	if err = doc.SetUserCSS("", true); err != nil {
+		t.Fatal(err)
+	}
+	if doc.PageCount() != plain {
+		t.Errorf("expected %d pages once the style sheet is removed, got %d", plain, doc.PageCount())
+	}
+
+	pdfDoc := loadTestDocument(t)
+	if err = pdfDoc.SetUserCSS("p { font-size: 36pt; }", true); err != nil {
+		t.Errorf("expected no effect on a PDF, got %v", err)
+	}
+	doc.Release()
+	if err = doc.SetUserCSS("", true); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased, got %v", err)
+	}
+}
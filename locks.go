@@ -0,0 +1,104 @@
+package pdf
+
+/*
+#include <stdlib.h>
+#include <mupdf/fitz.h>
+
+#ifdef _WIN32
+#include <windows.h>
+typedef SRWLOCK doc_mutex;
+#define doc_mutex_init(m) InitializeSRWLock(m)
+#define doc_mutex_destroy(m)
+#define doc_mutex_lock(m) AcquireSRWLockExclusive(m)
+#define doc_mutex_unlock(m) ReleaseSRWLockExclusive(m)
+#else
+#include <pthread.h>
+typedef pthread_mutex_t doc_mutex;
+#define doc_mutex_init(m) pthread_mutex_init(m, NULL)
+#define doc_mutex_destroy(m) pthread_mutex_destroy(m)
+#define doc_mutex_lock(m) pthread_mutex_lock(m)
+#define doc_mutex_unlock(m) pthread_mutex_unlock(m)
+#endif
+
+// The locks MuPDF needs to share a context's resources, such as its store and glyph cache, with clones of it that run
+// on other threads. The fz_locks_context comes first so a pointer to it is also a pointer to the whole.
+typedef struct {
+	fz_locks_context locks;
+	doc_mutex mutexes[FZ_LOCK_MAX];
+} doc_locks;
+
+static void lock_doc_mutex(void *user, int lock) {
+	doc_mutex_lock(&((doc_locks *)user)->mutexes[lock]);
+}
+
+static void unlock_doc_mutex(void *user, int lock) {
+	doc_mutex_unlock(&((doc_locks *)user)->mutexes[lock]);
+}
+
+// Returns a new set of locks, or NULL if the allocation failed. The caller must free them with drop_doc_locks once the
+// context they were given to, and every clone of it, has been dropped.
+fz_locks_context *new_doc_locks(void) {
+	doc_locks *l = malloc(sizeof(doc_locks));
+	if (l == NULL) {
+		return NULL;
+	}
+	for (int i = 0; i < FZ_LOCK_MAX; i++) {
+		doc_mutex_init(&l->mutexes[i]);
+	}
+	l->locks.user = l;
+	l->locks.lock = lock_doc_mutex;
+	l->locks.unlock = unlock_doc_mutex;
+	return &l->locks;
+}
+
+void drop_doc_locks(fz_locks_context *locks) {
+	doc_locks *l = (doc_locks *)locks;
+	for (int i = 0; i < FZ_LOCK_MAX; i++) {
+		doc_mutex_destroy(&l->mutexes[i]);
+	}
+	free(l);
+}
+
+// Returns a clone of the context, which shares its resources but may be used on another thread, or NULL if it threw.
+// The caller must drop the returned context.
+fz_context *wrapped_fz_clone_context(fz_context *ctx) {
+	fz_context *clone = NULL;
+	fz_var(clone);
+	fz_try(ctx) {
+		clone = fz_clone_context(ctx);
+	}
+	fz_catch(ctx) {
+		clone = NULL;
+	}
+	return clone;
+}
+*/
+import "C"
+
+// newLocks returns a new set of locks for a context, or nil if they could not be allocated.
+func newLocks() *C.fz_locks_context {
+	return C.new_doc_locks()
+}
+
+// dropLocks frees locks returned by newLocks. The context they were given to, and every clone of it, must already have
+// been dropped.
+func dropLocks(locks *C.fz_locks_context) {
+	C.drop_doc_locks(locks)
+}
+
+// cloneContext returns a clone of the document's context for use outside of d.lock, or nil if that fails. Until the
+// clone is dropped with dropClone, releasing the document waits for it. The caller must hold d.lock and must have
+// checked that the document has not been released.
+func (d *document) cloneContext() *C.fz_context {
+	clone := C.wrapped_fz_clone_context(d.ctx)
+	if clone != nil {
+		d.inflight.Add(1)
+	}
+	return clone
+}
+
+// dropClone drops a context returned by cloneContext. It does not need d.lock.
+func (d *document) dropClone(clone *C.fz_context) {
+	C.fz_drop_context(clone)
+	d.inflight.Done()
+}
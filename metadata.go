@@ -0,0 +1,247 @@
+package pdf
+
+/*
+#include <stdlib.h>
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+// Returns a newly allocated copy of the metadata value for key, or NULL if there is none or it threw. The caller must
+// free the returned string.
+char *wrapped_fz_lookup_metadata(fz_context *ctx, fz_document *doc, const char *key) {
+	char *value = NULL;
+	int size;
+	fz_var(value);
+	fz_try(ctx) {
+		size = fz_lookup_metadata(ctx, doc, key, NULL, 0);
+		if (size > 0) {
+			value = fz_malloc(ctx, size);
+			fz_lookup_metadata(ctx, doc, key, value, size);
+			value[size - 1] = 0;
+		}
+	}
+	fz_catch(ctx) {
+		fz_free(ctx, value);
+		value = NULL;
+	}
+	return value;
+}
+
+// Returns the decoded contents of the XMP metadata stream referenced by the document's catalog, or NULL if there isn't
+// one or it threw. The caller must drop the returned buffer.
+fz_buffer *wrapped_load_xmp(fz_context *ctx, fz_document *doc) {
+	fz_buffer *buf = NULL;
+	pdf_document *pdf = pdf_specifics(ctx, doc);
+	pdf_obj *obj;
+	if (pdf == NULL) {
+		return NULL;
+	}
+	fz_var(buf);
+	fz_try(ctx) {
+		obj = pdf_dict_getl(ctx, pdf_trailer(ctx, pdf), PDF_NAME(Root), PDF_NAME(Metadata), NULL);
+		if (pdf_is_stream(ctx, obj)) {
+			buf = pdf_load_stream(ctx, obj);
+		}
+	}
+	fz_catch(ctx) {
+		buf = NULL;
+	}
+	return buf;
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Metadata holds the descriptive information recorded in a document.
+type Metadata struct {
+	// Created is the time the document was created, or the zero value if it is not recorded or cannot be parsed.
+	Created time.Time
+	// Modified is the time the document was last modified, or the zero value if it is not recorded or cannot be
+	// parsed.
+	Modified time.Time
+	// XMP is the root element of the document's XMP metadata packet, or nil if there isn't one or it is not well-formed
+	// XML.
+	XMP *XMPNode
+	// Title, Author, Subject, Keywords, Creator, and Producer are taken from the document's Info dictionary.
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+	Producer string
+	// Format describes the document's format, such as "PDF 1.7".
+	Format string
+	// Version is the version of the PDF specification the document declares, such as "1.7".
+	Version string
+	// RawXMP holds the XMP metadata packet exactly as stored in the document, or nil if there isn't one.
+	RawXMP []byte
+}
+
+// XMPNode is an element of an XMP metadata packet.
+type XMPNode struct {
+	Name xml.Name
+	// Text holds the character data that appears directly within the element, with surrounding whitespace removed.
+	Text     string
+	Attrs    []xml.Attr
+	Children []*XMPNode
+}
+
+// Metadata returns the descriptive information recorded in the document.
+func (d *Document) Metadata() (*Metadata, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	m := &Metadata{
+		Title:    d.lookupMetadata(C.FZ_META_INFO_TITLE),
+		Author:   d.lookupMetadata(C.FZ_META_INFO_AUTHOR),
+		Subject:  d.lookupMetadata(C.FZ_META_INFO_SUBJECT),
+		Keywords: d.lookupMetadata(C.FZ_META_INFO_KEYWORDS),
+		Creator:  d.lookupMetadata(C.FZ_META_INFO_CREATOR),
+		Producer: d.lookupMetadata(C.FZ_META_INFO_PRODUCER),
+		Format:   d.lookupMetadata(C.FZ_META_FORMAT),
+		Created:  parsePDFDate(d.lookupMetadata(C.FZ_META_INFO_CREATIONDATE)),
+		Modified: parsePDFDate(d.lookupMetadata(C.FZ_META_INFO_MODIFICATIONDATE)),
+	}
+	m.Version = strings.TrimPrefix(m.Format, "PDF ")
+	if m.Version == m.Format {
+		m.Version = ""
+	}
+	if buf := C.wrapped_load_xmp(d.ctx, d.doc); buf != nil {
+		defer C.fz_drop_buffer(d.ctx, buf)
+		var data *C.uchar
+		if size := C.fz_buffer_storage(d.ctx, buf, &data); data != nil && size != 0 && size <= math.MaxInt32 {
+			m.RawXMP = C.GoBytes(unsafe.Pointer(data), C.int(size))
+			m.XMP = parseXMP(m.RawXMP)
+		}
+	}
+	return m, nil
+}
+
+// lookupMetadata returns the sanitized metadata value for key, or an empty string if there is none. The caller must hold
+// d.lock and must have checked that the document has not been released.
+func (d *Document) lookupMetadata(key string) string {
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+	value := C.wrapped_fz_lookup_metadata(d.ctx, d.doc, cKey)
+	if value == nil {
+		return ""
+	}
+	defer C.fz_free(d.ctx, unsafe.Pointer(value))
+	return sanitizeString(value)
+}
+
+// parsePDFDate parses a date in the format used by PDF, "D:YYYYMMDDHHmmSSOHH'mm'", where everything after the year is
+// optional, returning the zero value if it cannot be parsed or any of its fields is out of range. A missing time zone
+// is taken to be UTC.
+func parsePDFDate(str string) time.Time {
+	str = strings.TrimPrefix(strings.TrimSpace(str), "D:")
+	fields := []int{0, 1, 1, 0, 0, 0}
+	for i, width := range []int{4, 2, 2, 2, 2, 2} {
+		value, ok := parseDigits(str, width)
+		if !ok {
+			if i == 0 {
+				return time.Time{}
+			}
+			break
+		}
+		fields[i] = value
+		str = str[width:]
+	}
+	if fields[1] < 1 || fields[1] > 12 || fields[2] < 1 ||
+		fields[2] > time.Date(fields[0], time.Month(fields[1])+1, 0, 0, 0, 0, 0, time.UTC).Day() ||
+		fields[3] > 23 || fields[4] > 59 || fields[5] > 59 {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		sign := 1
+		if str[0] == '-' {
+			sign = -1
+		}
+		str = strings.ReplaceAll(str[1:], "'", "")
+		var offset int
+		if hours, ok := parseDigits(str, 2); ok {
+			if hours > 23 {
+				return time.Time{}
+			}
+			offset = hours * 3600
+			if minutes, ok := parseDigits(str[2:], 2); ok {
+				if minutes > 59 {
+					return time.Time{}
+				}
+				offset += minutes * 60
+			}
+		}
+		loc = time.FixedZone("", sign*offset)
+	}
+	return time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc)
+}
+
+// parseDigits returns the value of the first width characters of str, or false if there are fewer than that or they
+// are not all decimal digits.
+func parseDigits(str string, width int) (int, bool) {
+	if len(str) < width {
+		return 0, false
+	}
+	value := 0
+	for i := range width {
+		if str[i] < '0' || str[i] > '9' {
+			return 0, false
+		}
+		value = value*10 + int(str[i]-'0')
+	}
+	return value, true
+}
+
+// parseXMP parses an XMP packet into a tree of its elements, returning the root element, or nil if the packet is not
+// well-formed XML.
+func parseXMP(data []byte) *XMPNode {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root *XMPNode
+	var stack []*XMPNode
+	var text [][]byte
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(stack) == 0 {
+				return root
+			}
+			return nil
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &XMPNode{Name: t.Name, Attrs: t.Attr}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil
+				}
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			}
+			stack = append(stack, node)
+			text = append(text, nil)
+		case xml.EndElement:
+			stack[len(stack)-1].Text = strings.TrimSpace(string(text[len(text)-1]))
+			stack = stack[:len(stack)-1]
+			text = text[:len(text)-1]
+		case xml.CharData:
+			if len(text) != 0 {
+				text[len(text)-1] = append(text[len(text)-1], t...)
+			}
+		default:
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package pdf_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/richardwilkes/pdf"
+)
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:format>application/pdf</dc:format>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+// metadataPDF returns a minimal single-page PDF 1.6 document with the given Info dictionary and, if xmp is not empty,
+// an XMP metadata stream.
+func metadataPDF(info, xmp string) []byte {
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	if xmp != "" {
+		catalog = "<< /Type /Catalog /Pages 2 0 R /Metadata 5 0 R >>"
+	}
+	return fmt.Appendf(nil, `%%PDF-1.6
+1 0 obj
+%s
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] >>
+endobj
+4 0 obj
+%s
+endobj
+5 0 obj
+<< /Type /Metadata /Subtype /XML /Length %d >>
+stream
+%s
+endstream
+endobj
+trailer
+<< /Root 1 0 R /Info 4 0 R /Size 6 >>
+startxref
+0
+%%%%EOF
+`, catalog, info, len(xmp)+1, xmp)
+}
+
+func TestMetadata(t *testing.T) {
+	doc, err := pdf.New(metadataPDF(`<< /Title (A Title) /Author (An Author) /Subject (A Subject) /Keywords (one, two)
+/Creator (A Creator) /Producer (A Producer) /CreationDate (D:20240102030405+06'30') /ModDate (D:2025) >>`, testXMP), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	m, err := doc.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, one := range []struct{ field, got, want string }{
+		{"Title", m.Title, "A Title"},
+		{"Author", m.Author, "An Author"},
+		{"Subject", m.Subject, "A Subject"},
+		{"Keywords", m.Keywords, "one, two"},
+		{"Creator", m.Creator, "A Creator"},
+		{"Producer", m.Producer, "A Producer"},
+		{"Format", m.Format, "PDF 1.6"},
+		{"Version", m.Version, "1.6"},
+	} {
+		if one.got != one.want {
+			t.Errorf("expected %s %q, got %q", one.field, one.want, one.got)
+		}
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 6*3600+30*60)); !m.Created.Equal(want) {
+		t.Errorf("expected Created %v, got %v", want, m.Created)
+	}
+	if want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !m.Modified.Equal(want) {
+		t.Errorf("expected Modified %v, got %v", want, m.Modified)
+	}
+	if string(m.RawXMP) != testXMP+"\n" && string(m.RawXMP) != testXMP {
+		t.Errorf("expected the raw XMP packet, got %q", m.RawXMP)
+	}
+	if m.XMP == nil || m.XMP.Name.Local != "xmpmeta" || len(m.XMP.Children) != 1 {
+		t.Fatalf("expected an xmpmeta root with one child, got %+v", m.XMP)
+	}
+	description := m.XMP.Children[0].Children[0]
+	if len(description.Children) != 1 || description.Children[0].Name.Space != "http://purl.org/dc/elements/1.1/" ||
+		description.Children[0].Text != "application/pdf" {
+		t.Errorf("expected a dc:format element holding application/pdf, got %+v", description.Children)
+	}
+
+	doc.Release()
+	if _, err = doc.Metadata(); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from Metadata after release, got %v", err)
+	}
+}
+
+func TestMetadataMissing(t *testing.T) {
+	doc, err := pdf.New(metadataPDF("<< /CreationDate (not a date) >>", ""), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	m, err := doc.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "" || !m.Created.IsZero() || !m.Modified.IsZero() || m.XMP != nil || m.RawXMP != nil {
+		t.Errorf("expected empty metadata, got %+v", m)
+	}
+}
+
+func TestMetadataDates(t *testing.T) {
+	for _, one := range []struct {
+		want time.Time
+		date string
+	}{
+		{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), "D:20240229"},
+		{time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC), "D:20241231235959Z"},
+		{time.Time{}, "D:202413"},
+		{time.Time{}, "D:20240132"},
+		{time.Time{}, "D:20230229"},
+		{time.Time{}, "D:2024010225"},
+		{time.Time{}, "D:202401020360"},
+		{time.Time{}, "D:20240102030460"},
+		{time.Time{}, "D:20240102030405+24'00'"},
+		{time.Time{}, "D:20240102030405-05'60'"},
+	} {
+		doc, err := pdf.New(metadataPDF("<< /CreationDate ("+one.date+") >>", ""), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := doc.Metadata()
+		doc.Release()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.Created.Equal(one.want) || m.Created.IsZero() != one.want.IsZero() {
+			t.Errorf("expected %q to give %v, got %v", one.date, one.want, m.Created)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pdf_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestNewWithMIME(t *testing.T) {
+	const svg = `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="50">` +
+		`<rect width="100" height="50" fill="#ff0000"/></svg>`
+	for _, mime := range []string{"image/svg+xml", ".svg", ""} {
+		doc, err := pdf.NewWithMIME([]byte(svg), mime, 0)
+		if err != nil {
+			t.Fatalf("%q: %v", mime, err)
+		}
+		if doc.PageCount() != 1 {
+			t.Errorf("%q: expected 1 page, got %d", mime, doc.PageCount())
+		}
+		page, err := doc.RenderPage(0, 72, 0, "")
+		if err != nil {
+			t.Fatalf("%q: %v", mime, err)
+		}
+		if b := page.Image.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+			t.Errorf("%q: expected a 100×50 image, got %v", mime, b)
+		}
+		if c := page.Image.NRGBAAt(50, 25); c != (color.NRGBA{R: 255, A: 255}) {
+			t.Errorf("%q: expected a red pixel, got %v", mime, c)
+		}
+		if _, err = doc.PageInfo(0); err != nil {
+			t.Errorf("%q: %v", mime, err)
+		}
+		if label, labelErr := doc.PageLabel(0); labelErr != nil || label != "1" {
+			t.Errorf("%q: expected page label %q, got %q (%v)", mime, "1", label, labelErr)
+		}
+		doc.Release()
+	}
+
+	var buffer bytes.Buffer
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 20))
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := pdf.NewWithMIME(buffer.Bytes(), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.PageCount() != 1 {
+		t.Errorf("expected 1 page for a PNG image, got %d", doc.PageCount())
+	}
+	doc.Release()
+
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = pdf.NewWithMIME(data, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if m, metaErr := doc.Metadata(); metaErr != nil || m.Version == "" {
+		t.Errorf("expected a sniffed PDF to report its version, got %+v (%v)", m, metaErr)
+	}
+	doc.Release()
+
+	if _, err = pdf.NewWithMIME([]byte{0, 0, 0x80, 0x81, 0x82, 0}, "", 0); !errors.Is(err, pdf.ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat for unrecognized data, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"runtime"
+)
+
+// Page is a page of a Document, as returned by LoadPage. It keeps the page's display list and links, so that rendering,
+// searching, and extracting text from it any number of times, at any scale, does not repeat that work. Methods on this
+// are safe to use from multiple goroutines. They share the document's lock with the methods of the Document.
+type Page struct {
+	// loadedPage is held by pointer for the same reason Document holds document by pointer.
+	*loadedPage
+	// doc keeps the document from being collected while the page is still in use.
+	doc *Document
+}
+
+type loadedPage struct {
+	owner       *document
+	page        *C.fz_page
+	displayList *C.fz_display_list
+	links       []resolvedLink
+	number      int
+}
+
+// LoadPage loads the specified page and builds its display list, returning a Page that can be used repeatedly. It is
+// not necessary to call Release on the returned Page, as garbage collection will eventually do this for you, however,
+// doing so explicitly will cause an immediate reclamation of the memory it holds. Releasing the document releases all
+// of its pages. If ctx is cancelled, the load is abandoned and ctx.Err() is returned.
+func (d *Document) LoadPage(ctx context.Context, pageNumber int) (*Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	p, err := d.newPage(pageNumber, c)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	if err = c.check(nil); err != nil {
+		// The display list may be incomplete.
+		p.Release()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (d *Document) newPage(pageNumber int, c *cookie) (*Page, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	page, err := d.loadPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	displayList := d.newDisplayList(page, c)
+	if displayList == nil {
+		C.fz_drop_page(d.ctx, page)
+		return nil, ErrUnableToLoadPage
+	}
+	p := &Page{
+		loadedPage: &loadedPage{
+			owner:       d.document,
+			page:        page,
+			displayList: displayList,
+			links:       d.resolveLinks(page),
+			number:      pageNumber,
+		},
+		doc: d,
+	}
+	if d.pages == nil {
+		d.pages = make(map[*loadedPage]struct{})
+	}
+	d.pages[p.loadedPage] = struct{}{}
+	runtime.AddCleanup(p, func(lp *loadedPage) { lp.release() }, p.loadedPage)
+	return p, nil
+}
+
+// PageNumber returns the number of the page within its document.
+func (p *Page) PageNumber() int {
+	return p.number
+}
+
+// Render renders the page at the requested dpi. If needle is not empty, then the bounding boxes of the text on the page
+// that matches it, as controlled by opts, will be returned. If ctx is cancelled, the render is abandoned and ctx.Err()
+// is returned.
+func (p *Page) Render(ctx context.Context, dpi int, needle string, opts *SearchOptions) (*RenderedPage, error) {
+	return p.render(ctx, needle, opts, func(*C.fz_page) (Matrix, error) {
+		return dpiToMatrix(dpi), nil
+	}, nil)
+}
+
+// RenderWithOptions renders the page as described by opts, which may be nil for the defaults. If ctx is cancelled, the
+// render is abandoned and ctx.Err() is returned.
+func (p *Page) RenderWithOptions(ctx context.Context, opts *RenderOptions) (*RenderedPage, error) {
+	needle, searchOpts := opts.search()
+	m, err := opts.transform()
+	if err != nil {
+		return nil, err
+	}
+	return p.render(ctx, needle, searchOpts, func(*C.fz_page) (Matrix, error) { return m, nil }, opts)
+}
+
+// RenderInto renders the page straight into the pixels of dst, as RenderPageInto does for a Document.
+func (p *Page) RenderInto(ctx context.Context, dst image.Image, opts *RenderOptions) (*RenderedPage, error) {
+	opts, err := opts.forImage(dst)
+	if err != nil {
+		return nil, err
+	}
+	needle, searchOpts := opts.search()
+	s, err := newSearcher(needle, searchOpts)
+	if err != nil {
+		return nil, err
+	}
+	m, err := opts.transform()
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.runRender(ctx, opts, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		p.owner.lock.Lock()
+		defer p.owner.lock.Unlock()
+		if err = p.releasedErr(); err != nil {
+			return nil, nil, err
+		}
+		rendered, job, prepareErr := p.doc.prepareJob(p.displayList, p.links, p.number, m, s, searchOpts.maxHits(), c)
+		if prepareErr != nil {
+			return nil, nil, prepareErr
+		}
+		renderInto(job, dst)
+		return rendered, job, nil
+	})
+}
+
+// RenderForSize renders the page to fit within the requested size. If needle is not empty, then the bounding boxes of
+// the text on the page that matches it, as controlled by opts, will be returned. If ctx is cancelled, the render is
+// abandoned and ctx.Err() is returned.
+func (p *Page) RenderForSize(ctx context.Context, maxWidth, maxHeight int, needle string, opts *SearchOptions) (*RenderedPage, error) {
+	return p.render(ctx, needle, opts, p.doc.scaleToFit(maxWidth, maxHeight), nil)
+}
+
+func (p *Page) render(ctx context.Context, needle string, opts *SearchOptions, transformFor func(page *C.fz_page) (Matrix, error), renderOpts *RenderOptions) (*RenderedPage, error) {
+	s, err := newSearcher(needle, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.runRender(ctx, renderOpts, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		p.owner.lock.Lock()
+		defer p.owner.lock.Unlock()
+		if err = p.releasedErr(); err != nil {
+			return nil, nil, err
+		}
+		var m Matrix
+		if m, err = transformFor(p.page); err != nil {
+			return nil, nil, err
+		}
+		return p.doc.prepareJob(p.displayList, p.links, p.number, m, s, opts.maxHits(), c)
+	})
+}
+
+// Search returns the text on the page that matches needle, as controlled by opts, in the pixel space of the page
+// rendered at the requested dpi. If ctx is cancelled, the search is abandoned and ctx.Err() is returned.
+func (p *Page) Search(ctx context.Context, needle string, dpi int, opts *SearchOptions) ([]*SearchHit, error) {
+	s, err := newSearcher(needle, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err = p.releasedErr(); err != nil {
+		return nil, err
+	}
+	matches, err := p.doc.search(p.displayList, s, opts.maxHits(), c)
+	if err = c.check(err); err != nil {
+		return nil, err
+	}
+	return transformMatches(matches, dpiToMatrix(dpi), 0), nil
+}
+
+// StructuredText returns the structured text of the page, as PageText does for a Document. If ctx is cancelled, the
+// extraction is abandoned and ctx.Err() is returned.
+func (p *Page) StructuredText(ctx context.Context, dpi int, opts *TextOptions) (*TextPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return nil, err
+	}
+	stext := p.doc.newStructuredText(p.displayList, opts.flags(), c)
+	if stext == nil {
+		return nil, c.check(ErrUnableToExtractText)
+	}
+	defer C.fz_drop_stext_page(p.owner.ctx, stext)
+	if err := c.check(nil); err != nil {
+		return nil, err
+	}
+	return p.doc.buildTextPage(stext, dpiToScale(dpi)), nil
+}
+
+// Text returns the plain text of the page, as Text does for a Document. If ctx is cancelled, the extraction is
+// abandoned and ctx.Err() is returned.
+func (p *Page) Text(ctx context.Context, opts *TextOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return "", err
+	}
+	stext := p.doc.newStructuredText(p.displayList, opts.flags(), c)
+	if stext == nil {
+		return "", c.check(ErrUnableToExtractText)
+	}
+	defer C.fz_drop_stext_page(p.owner.ctx, stext)
+	text, err := p.doc.flattenText(stext, opts)
+	if err = c.check(err); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// Links returns the links on the page, in the pixel space of the page rendered at the requested dpi.
+func (p *Page) Links(dpi int) ([]*PageLink, error) {
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	if err := p.releasedErr(); err != nil {
+		return nil, err
+	}
+	return scaleLinks(p.links, dpiToScale(dpi)), nil
+}
+
+// Release the page, releasing any resources it holds. The document is not affected.
+func (p *Page) Release() {
+	p.release()
+}
+
+func (p *loadedPage) release() {
+	p.owner.lock.Lock()
+	defer p.owner.lock.Unlock()
+	p.drop()
+}
+
+// drop frees the page's resources, if that has not already been done. The caller must hold the document lock.
+func (p *loadedPage) drop() {
+	if p.page == nil {
+		return
+	}
+	C.fz_drop_display_list(p.owner.ctx, p.displayList)
+	C.fz_drop_page(p.owner.ctx, p.page)
+	p.displayList = nil
+	p.page = nil
+	p.links = nil
+	delete(p.owner.pages, p)
+}
+
+// releasedErr returns ErrDocumentReleased or ErrPageReleased if the document or the page has been released, or nil
+// otherwise. The caller must hold the document lock.
+func (p *loadedPage) releasedErr() error {
+	if p.owner.released() {
+		return ErrDocumentReleased
+	}
+	if p.page == nil {
+		return ErrPageReleased
+	}
+	return nil
+}
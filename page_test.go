@@ -0,0 +1,127 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestLoadPage(t *testing.T) {
+	doc := loadTestDocument(t)
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.PageNumber() != 0 {
+		t.Errorf("expected page number 0, got %d", page.PageNumber())
+	}
+
+	// Rendering the loaded page at several scales must match rendering through the document.
+	for _, dpi := range []int{50, 100, 200} {
+		got, renderErr := page.Render(context.Background(), dpi, "GURPS", nil)
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		want, renderErr := doc.RenderPageWithSearch(context.Background(), 0, dpi, "GURPS", nil)
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		if got.Image.Rect != want.Image.Rect || !bytes.Equal(got.Image.Pix, want.Image.Pix) {
+			t.Errorf("dpi %d: image differs from RenderPageWithSearch", dpi)
+		}
+		if len(got.SearchHits) == 0 || len(got.SearchHits) != len(want.SearchHits) {
+			t.Errorf("dpi %d: expected %d search hits, got %d", dpi, len(want.SearchHits), len(got.SearchHits))
+		}
+		if len(got.Links) != len(want.Links) {
+			t.Errorf("dpi %d: expected %d links, got %d", dpi, len(want.Links), len(got.Links))
+		}
+		links, linksErr := page.Links(dpi)
+		if linksErr != nil {
+			t.Fatal(linksErr)
+		}
+		for i, link := range links {
+			if *link != *want.Links[i] {
+				t.Errorf("dpi %d: link %d: expected %+v, got %+v", dpi, i, want.Links[i], link)
+			}
+		}
+		hits, searchErr := page.Search(context.Background(), "GURPS", dpi, nil)
+		if searchErr != nil {
+			t.Fatal(searchErr)
+		}
+		if len(hits) != len(want.Hits) {
+			t.Errorf("dpi %d: expected %d hits from Search, got %d", dpi, len(want.Hits), len(hits))
+		}
+	}
+
+	sized, err := page.RenderForSize(context.Background(), 300, 300, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSized, err := doc.RenderPageForSize(0, 300, 300, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sized.Image.Rect != wantSized.Image.Rect {
+		t.Errorf("expected bounds %v, got %v", wantSized.Image.Rect, sized.Image.Rect)
+	}
+
+	text, err := page.Text(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := doc.Text(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != want {
+		t.Error("expected the page's text to match Document.Text")
+	}
+	structured, err := page.StructuredText(context.Background(), 72, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(structured.Blocks) == 0 {
+		t.Error("expected structured text blocks")
+	}
+
+	page.Release()
+	page.Release()
+	if _, err = page.Render(context.Background(), 72, "", nil); !errors.Is(err, pdf.ErrPageReleased) {
+		t.Errorf("expected ErrPageReleased after release, got %v", err)
+	}
+	if _, err = doc.RenderPage(0, 72, 0, ""); err != nil {
+		t.Errorf("expected the document to be unaffected by releasing a page, got %v", err)
+	}
+}
+
+func TestLoadPageErrors(t *testing.T) {
+	doc := loadTestDocument(t)
+	if _, err := doc.LoadPage(context.Background(), doc.PageCount()); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := doc.LoadPage(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// Releasing the document releases its pages.
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Release()
+	if _, err = page.Text(context.Background(), nil); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased, got %v", err)
+	}
+	if _, err = page.Links(72); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased, got %v", err)
+	}
+	page.Release()
+	if _, err = doc.LoadPage(context.Background(), 0); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased, got %v", err)
+	}
+}
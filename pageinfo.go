@@ -0,0 +1,138 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+// Returns the named box of the page, falling back to def if it is missing or malformed.
+static fz_rect page_box(fz_context *ctx, pdf_obj *pageobj, pdf_obj *name, int inheritable, fz_rect def) {
+	pdf_obj *obj = inheritable ? pdf_dict_get_inheritable(ctx, pageobj, name) : pdf_dict_get(ctx, pageobj, name);
+	fz_rect box;
+	if (!pdf_is_array(ctx, obj)) {
+		return def;
+	}
+	box = pdf_to_rect(ctx, obj);
+	return fz_is_empty_rect(box) ? def : box;
+}
+
+// Fills boxes with the boxes of a page in a document that is not a PDF, which has to be loaded to find them. Returns 0
+// if it threw.
+static int other_page_info(fz_context *ctx, fz_document *doc, int number, fz_rect *boxes) {
+	fz_page *page = NULL;
+	int ok = 0;
+	fz_var(page);
+	fz_var(ok);
+	fz_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+		for (int i = FZ_MEDIA_BOX; i <= FZ_ART_BOX; i++) {
+			boxes[i - FZ_MEDIA_BOX] = fz_bound_page_box(ctx, page, (fz_box_type)i);
+		}
+		ok = 1;
+	}
+	fz_always(ctx) {
+		fz_drop_page(ctx, page);
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
+// Fills boxes with the media, crop, bleed, trim, and art boxes of the page, in that order, transformed into the same
+// space as fz_bound_page, along with its rotation and user unit. For a PDF, this is done without loading the page.
+// Returns 0 if it threw.
+int wrapped_page_info(fz_context *ctx, fz_document *doc, int number, fz_rect *boxes, int *rotate, float *user_unit) {
+	pdf_document *pdf = pdf_specifics(ctx, doc);
+	pdf_obj *pageobj;
+	fz_matrix ctm;
+	fz_rect media, crop;
+	int ok = 0;
+	*rotate = 0;
+	*user_unit = 1;
+	if (pdf == NULL) {
+		return other_page_info(ctx, doc, number, boxes);
+	}
+	fz_var(ok);
+	fz_try(ctx) {
+		pageobj = pdf_lookup_page_obj(ctx, pdf, number);
+		pdf_page_obj_transform(ctx, pageobj, NULL, &ctm);
+		media = page_box(ctx, pageobj, PDF_NAME(MediaBox), 1, fz_make_rect(0, 0, 612, 792));
+		crop = page_box(ctx, pageobj, PDF_NAME(CropBox), 1, media);
+		boxes[0] = fz_transform_rect(media, ctm);
+		boxes[1] = fz_transform_rect(crop, ctm);
+		boxes[2] = fz_transform_rect(page_box(ctx, pageobj, PDF_NAME(BleedBox), 0, crop), ctm);
+		boxes[3] = fz_transform_rect(page_box(ctx, pageobj, PDF_NAME(TrimBox), 0, crop), ctm);
+		boxes[4] = fz_transform_rect(page_box(ctx, pageobj, PDF_NAME(ArtBox), 0, crop), ctm);
+		*rotate = pdf_to_int(ctx, pdf_dict_get_inheritable(ctx, pageobj, PDF_NAME(Rotate)));
+		*user_unit = pdf_dict_get_real_default(ctx, pageobj, PDF_NAME(UserUnit), 1);
+		ok = 1;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+*/
+import "C"
+
+// PageInfo holds the geometry of a page. The boxes are in points (1/72 of an inch, or of UserUnit if that is not 1),
+// in the same top-left origin, y-down space used for a page rendered at 72 dpi, with the page's rotation applied.
+type PageInfo struct {
+	// MediaBox is the full extent of the page.
+	MediaBox Rect
+	// CropBox is the visible region of the page, which is what is rendered. It matches MediaBox if not specified.
+	CropBox Rect
+	// BleedBox is the region to which the page should be clipped in a production environment. It matches CropBox if
+	// not specified.
+	BleedBox Rect
+	// TrimBox is the intended extent of the finished page after trimming. It matches CropBox if not specified.
+	TrimBox Rect
+	// ArtBox is the extent of the page's meaningful content. It matches CropBox if not specified.
+	ArtBox Rect
+	// Rotation is the clockwise rotation of the page when displayed, in degrees: 0, 90, 180, or 270.
+	Rotation int
+	// UserUnit is the size of a unit in the page's coordinate space, in multiples of 1/72 of an inch. It is 1 if not
+	// specified.
+	UserUnit float64
+}
+
+// PageInfo returns the geometry of the specified page. For a PDF, this reads the page's dictionary only, without loading
+// or rendering its content, so it is suitable for laying out many pages up front. For other formats, the page is
+// loaded, and all of the boxes match its bounds.
+func (d *Document) PageInfo(pageNumber int) (*PageInfo, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	if pageNumber < 0 || pageNumber >= d.pageCount() {
+		return nil, ErrInvalidPageNumber
+	}
+	var boxes [5]C.fz_rect
+	var rotate C.int
+	var userUnit C.float
+	if C.wrapped_page_info(d.ctx, d.doc, C.int(pageNumber), &boxes[0], &rotate, &userUnit) == 0 {
+		return nil, ErrUnableToLoadPage
+	}
+	rotation := int(rotate) % 360
+	if rotation < 0 {
+		rotation += 360
+	}
+	return &PageInfo{
+		MediaBox: toRect(boxes[0]),
+		CropBox:  toRect(boxes[1]),
+		BleedBox: toRect(boxes[2]),
+		TrimBox:  toRect(boxes[3]),
+		ArtBox:   toRect(boxes[4]),
+		Rotation: rotation - rotation%90,
+		UserUnit: float64(userUnit),
+	}, nil
+}
+
+// toRect converts an unscaled MuPDF rect into a Rect.
+func toRect(r C.fz_rect) Rect {
+	return Rect{
+		Min: Point{X: float64(r.x0), Y: float64(r.y0)},
+		Max: Point{X: float64(r.x1), Y: float64(r.y1)},
+	}
+}
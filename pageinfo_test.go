@@ -0,0 +1,85 @@
+package pdf_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+const boxesPDF = `%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 300] /Rotate 90 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /CropBox [10 10 190 290] /TrimBox [20 20 180 280] >>
+endobj
+trailer
+<< /Root 1 0 R /Size 4 >>
+startxref
+0
+%%EOF
+`
+
+func TestPageInfo(t *testing.T) {
+	doc, err := pdf.New([]byte(boxesPDF), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	info, err := doc.PageInfo(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Rotation != 90 {
+		t.Errorf("expected an inherited rotation of 90, got %d", info.Rotation)
+	}
+	if info.UserUnit != 1 {
+		t.Errorf("expected a user unit of 1, got %v", info.UserUnit)
+	}
+	// The 180×280 crop box, rotated a quarter turn, becomes 280×180 with its origin at the top-left.
+	crop := pdf.Rect{Max: pdf.Point{X: 280, Y: 180}}
+	for _, one := range []struct {
+		name string
+		got  pdf.Rect
+		want pdf.Rect
+	}{
+		{name: "MediaBox", got: info.MediaBox, want: pdf.Rect{Min: pdf.Point{X: -10, Y: -10}, Max: pdf.Point{X: 290, Y: 190}}},
+		{name: "CropBox", got: info.CropBox, want: crop},
+		{name: "BleedBox", got: info.BleedBox, want: crop},
+		{name: "TrimBox", got: info.TrimBox, want: pdf.Rect{Min: pdf.Point{X: 10, Y: 10}, Max: pdf.Point{X: 270, Y: 170}}},
+		{name: "ArtBox", got: info.ArtBox, want: crop},
+	} {
+		if !rectsClose(one.got, one.want) {
+			t.Errorf("expected %s %+v, got %+v", one.name, one.want, one.got)
+		}
+	}
+
+	// The crop box is the area that gets rendered.
+	page, err := doc.RenderPage(0, 72, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := page.Image.Bounds(); b.Dx() != int(math.Round(info.CropBox.Width())) ||
+		b.Dy() != int(math.Round(info.CropBox.Height())) {
+		t.Errorf("expected a rendered size matching the crop box %+v, got %v", info.CropBox, b)
+	}
+
+	if _, err = doc.PageInfo(1); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+	doc.Release()
+	if _, err = doc.PageInfo(0); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from PageInfo after release, got %v", err)
+	}
+}
+
+func rectsClose(a, b pdf.Rect) bool {
+	const epsilon = 0.01
+	return math.Abs(a.Min.X-b.Min.X) < epsilon && math.Abs(a.Min.Y-b.Min.Y) < epsilon &&
+		math.Abs(a.Max.X-b.Max.X) < epsilon && math.Abs(a.Max.Y-b.Max.Y) < epsilon
+}
@@ -4,8 +4,8 @@ package pdf
 #cgo CFLAGS: -Iinclude
 #cgo darwin,amd64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_darwin_amd64 -lm
 #cgo darwin,arm64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_darwin_arm64 -lm
-#cgo linux,amd64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_linux_amd64 -lm
-#cgo linux,arm64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_linux_arm64 -lm
+#cgo linux,amd64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_linux_amd64 -lm -lpthread
+#cgo linux,arm64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_linux_arm64 -lm -lpthread
 #cgo windows,amd64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_windows_amd64 -lm -Wl,--allow-multiple-definition
 #cgo windows,arm64 LDFLAGS: -L${SRCDIR}/lib -lmupdf_windows_arm64 -lm -Wl,--allow-multiple-definition
 
@@ -18,11 +18,11 @@ fz_context *wrapped_fz_new_context(const fz_alloc_context *alloc, const fz_locks
 	return fz_new_context(alloc, locks, max_store);
 }
 
-fz_document *wrapped_fz_open_pdf_document_with_stream(fz_context *ctx, fz_stream *stream) {
+fz_document *wrapped_fz_open_document_with_stream(fz_context *ctx, const char *magic, fz_stream *stream) {
 	fz_document *doc = NULL;
 	fz_var(doc);
 	fz_try(ctx) {
-		doc = fz_open_document_with_stream(ctx, "application/pdf", stream);
+		doc = fz_open_document_with_stream(ctx, magic, stream);
 	}
 	fz_catch(ctx) {
 		doc = NULL;
@@ -30,6 +30,19 @@ fz_document *wrapped_fz_open_pdf_document_with_stream(fz_context *ctx, fz_stream
 	return doc;
 }
 
+// Returns 1 if a registered document handler recognizes the stream's content or magic, 0 if none does or it threw.
+int wrapped_fz_recognize_document_stream_content(fz_context *ctx, fz_stream *stream, const char *magic) {
+	int ok = 0;
+	fz_var(ok);
+	fz_try(ctx) {
+		ok = fz_recognize_document_stream_content(ctx, stream, magic) != NULL;
+	}
+	fz_catch(ctx) {
+		ok = 0;
+	}
+	return ok;
+}
+
 fz_stream *wrapped_fz_open_memory(fz_context *ctx, const unsigned char *data, size_t len) {
 	fz_stream *stream = NULL;
 	fz_var(stream);
@@ -42,28 +55,58 @@ fz_stream *wrapped_fz_open_memory(fz_context *ctx, const unsigned char *data, si
 	return stream;
 }
 
-fz_display_list *wrapped_fz_new_display_list_from_page(fz_context *ctx, fz_page *page) {
+fz_display_list *wrapped_fz_new_display_list_from_page(fz_context *ctx, fz_page *page, fz_cookie *cookie) {
 	fz_display_list *list = NULL;
+	fz_device *dev = NULL;
 	fz_var(list);
+	fz_var(dev);
 	fz_try(ctx) {
-		list = fz_new_display_list_from_page(ctx, page);
+		list = fz_new_display_list(ctx, fz_bound_page(ctx, page));
+		dev = fz_new_list_device(ctx, list);
+		fz_run_page(ctx, page, dev, fz_identity, cookie);
+		fz_close_device(ctx, dev);
+	}
+	fz_always(ctx) {
+		fz_drop_device(ctx, dev);
 	}
 	fz_catch(ctx) {
+		fz_drop_display_list(ctx, list);
 		list = NULL;
 	}
 	return list;
 }
 
-fz_pixmap *wrapped_fz_new_pixmap_from_display_list(fz_context *ctx, fz_display_list *list, fz_matrix ctm, fz_colorspace *cs, int alpha) {
+// Renders the display list, transformed by ctm, into samples, which hold a pixmap in cs covering bbox with the given
+// stride. The draw device is clipped to bbox, so only the part of the page within it is drawn. The pixmap is cleared to
+// transparent first if transparent is set, or filled with the background color, given in cs, otherwise. The samples are
+// not used once this returns. Returns 0 if it threw.
+int wrapped_render_display_list(fz_context *ctx, fz_display_list *list, fz_matrix ctm, fz_irect bbox, fz_colorspace *cs, int alpha, int transparent, float *background, unsigned char *samples, int stride, fz_cookie *cookie) {
 	fz_pixmap *pixmap = NULL;
+	fz_device *dev = NULL;
+	int ok = 1;
 	fz_var(pixmap);
+	fz_var(dev);
 	fz_try(ctx) {
-		pixmap = fz_new_pixmap_from_display_list(ctx, list, ctm, cs, alpha);
+		pixmap = fz_new_pixmap_with_data(ctx, cs, bbox.x1 - bbox.x0, bbox.y1 - bbox.y0, NULL, alpha, stride, samples);
+		pixmap->x = bbox.x0;
+		pixmap->y = bbox.y0;
+		if (transparent) {
+			fz_clear_pixmap(ctx, pixmap);
+		} else {
+			fz_fill_pixmap_with_color(ctx, pixmap, cs, background, fz_default_color_params);
+		}
+		dev = fz_new_draw_device_with_bbox(ctx, fz_identity, pixmap, &bbox);
+		fz_run_display_list(ctx, list, dev, ctm, fz_rect_from_irect(bbox), cookie);
+		fz_close_device(ctx, dev);
+	}
+	fz_always(ctx) {
+		fz_drop_device(ctx, dev);
+		fz_drop_pixmap(ctx, pixmap);
 	}
 	fz_catch(ctx) {
-		pixmap = NULL;
+		ok = 0;
 	}
-	return pixmap;
+	return ok;
 }
 
 int wrapped_fz_search_display_list(fz_context *ctx, fz_display_list *list, const char *needle, int *hit_mark, fz_quad *hit_bbox, int hit_max) {
@@ -230,6 +273,7 @@ import "C"
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"image"
 	"math"
@@ -240,18 +284,35 @@ import (
 	"unsafe"
 )
 
+// pdfMIMEType is the MIME type of PDF documents.
+const pdfMIMEType = "application/pdf"
+
 // Possible error values
 var (
 	ErrNotPDFData               = errors.New("only PDF documents are supported")
 	ErrUnableToCreatePDFContext = errors.New("unable to create PDF context")
 	ErrInternal                 = errors.New("internal error")
 	ErrUnableToOpenPDF          = errors.New("unable to open PDF")
+	ErrUnsupportedFormat        = errors.New("unsupported document format")
+	ErrUnableToLayout           = errors.New("unable to lay out document")
+	ErrInvalidBookmark          = errors.New("invalid bookmark")
 	ErrInvalidPageNumber        = errors.New("invalid page number")
 	ErrUnableToLoadPage         = errors.New("unable to load page")
 	ErrUnableToCreateImage      = errors.New("unable to create image")
 	ErrImageTooLarge            = errors.New("rendered image would be too large")
 	ErrInvalidPageSize          = errors.New("invalid page size")
 	ErrDocumentReleased         = errors.New("document has been released")
+	ErrPageReleased             = errors.New("page has been released")
+	ErrInvalidTile              = errors.New("invalid tile")
+	ErrInvalidTransform         = errors.New("invalid transform")
+	ErrUnsupportedImage         = errors.New("unsupported image")
+	ErrUnableToExtractText      = errors.New("unable to extract text")
+	ErrInvalidSearchPattern     = errors.New("invalid search pattern")
+	ErrPageLabelNotFound        = errors.New("page label not found")
+	ErrAnnotationNotFound       = errors.New("annotation not found")
+	ErrInvalidAnnotationType    = errors.New("invalid annotation type")
+	ErrUnableToEditAnnotation   = errors.New("unable to edit annotation")
+	ErrUnableToSave             = errors.New("unable to save document")
 )
 
 // Each of these variables is global and are not safe to modify when other calls to this code are being made. Generally,
@@ -263,15 +324,34 @@ var (
 	// OverallMaxLinks is the maximum number of links returned. This is here to safeguard against untrusted input that
 	// might otherwise cause an out of memory error.
 	OverallMaxLinks = 1000
+	// OverallMaxSelectionQuads is the maximum number of quads returned for a text selection. This is here to safeguard
+	// against untrusted input that might otherwise cause an out of memory error.
+	OverallMaxSelectionQuads = 1000
 	// OverallMaxTOCEntries is the maximum number of TOC entries returned. This is here to safeguard against untrusted
 	// input that might otherwise cause an out of memory error.
 	OverallMaxTOCEntries = 1000
+	// OverallMaxAnnotations is the maximum number of annotations returned for a page. This is here to safeguard against
+	// untrusted input that might otherwise cause an out of memory error.
+	OverallMaxAnnotations = 1000
+	// OverallMaxAnnotationQuads is the maximum number of quads returned for the quad points of each annotation. This is
+	// here to safeguard against untrusted input that might otherwise cause an out of memory error.
+	OverallMaxAnnotationQuads = 1000
+	// OverallMaxAnnotationPoints is the maximum number of points returned for the ink strokes or vertices of each
+	// annotation. This is here to safeguard against untrusted input that might otherwise cause an out of memory error.
+	OverallMaxAnnotationPoints = 100000
 	// OverallMaxPixels is the maximum number of pixels (width × height) a rendered page image may contain. Requests
 	// that would produce a larger image are rejected rather than attempting a very large allocation, safeguarding
 	// against untrusted input or bad sizing parameters that might otherwise cause an out of memory error. The default
 	// matches the largest image permitted by the internal 32-bit limit on the rendered buffer's byte size (4 bytes per
 	// pixel).
 	OverallMaxPixels = math.MaxInt32 / 4
+	// UserCSS is a style sheet applied to reflowable documents, such as EPUB, FB2, and HTML, when they are opened, on top
+	// of their own styles. It only affects documents opened after it is set; use Document.SetUserCSS to change the style
+	// sheet of a document that is already open.
+	UserCSS string
+	// UseDocumentCSS controls whether the style sheets embedded in reflowable documents are used when they are opened.
+	// It only affects documents opened after it is set.
+	UseDocumentCSS = true
 )
 
 // AuthenticationStatus holds the result of an authentication attempt. A non-zero value indicates success and the masks
@@ -286,15 +366,31 @@ const (
 )
 
 type document struct {
-	ctx  *C.fz_context
-	doc  *C.fz_document
-	data *C.uchar
-	lock sync.Mutex
+	ctx   *C.fz_context
+	doc   *C.fz_document
+	data  *C.uchar
+	locks *C.fz_locks_context
+	// layout holds the page size and font size the document was last laid out with by Layout, or nil if it has not
+	// been.
+	layout *layoutSize
+	// pages holds the pages loaded by LoadPage that have not been released yet, which must be dropped before doc is.
+	pages map[*loadedPage]struct{}
+	// magic is the hint to the document's format it was opened with, and dataSize is the size of data, which are kept
+	// so that reflowable documents can be opened again by SetUserCSS.
+	magic string
+	// inflight counts the clones of ctx in use outside of lock, which must all be dropped before ctx is.
+	inflight sync.WaitGroup
+	dataSize C.size_t
+	lock     sync.Mutex
 }
 
-// Document represents PDF document. Page numbers for the exposed API are zero-based. Methods on this are safe to use
-// from multiple goroutines. Calls into the underlying MuPDF library are serialized internally, so they execute one at a
-// time.
+// Document represents a PDF document, or one in any other format opened with NewWithMIME. Page numbers for the exposed
+// API are zero-based. Methods on this are safe to use from multiple goroutines. Most calls into the underlying MuPDF
+// library are serialized internally, so they execute one at a time: loading pages, building their display lists,
+// extracting text, searching, and editing the document. The exception is rasterization, the drawing of a page's display
+// list into its image, which the render methods, including RenderPages and those of Page, do outside of that
+// serialization, so renders of several pages proceed in parallel while the other calls go on. Release waits for any
+// rasterization in progress to finish before freeing the document.
 type Document struct {
 	// document is held by pointer so it lives in its own heap allocation, separate from the Document wrapper. This is
 	// required by runtime.AddCleanup(): the cleanup arg must not point into the same allocation as the tracked pointer,
@@ -304,7 +400,9 @@ type Document struct {
 
 // TOCEntry holds a single entry in the table of contents.
 type TOCEntry struct {
-	Title      string
+	Title string
+	// PageLabel is the label of the target page, as returned by PageLabel, or empty if there is no target page.
+	PageLabel  string
 	Children   []*TOCEntry
 	PageNumber int
 	PageX      int
@@ -314,7 +412,9 @@ type TOCEntry struct {
 // PageLink holds a single link on a page. If PageNumber is >= 0, then this is an internal link and the URI will be
 // empty.
 type PageLink struct {
-	URI        string
+	URI string
+	// PageLabel is the label of the target page of an internal link, as returned by PageLabel.
+	PageLabel  string
 	PageNumber int
 	// Bounds is the clickable hot-zone of the link on the page it appears on, in rendered-image pixel space.
 	Bounds image.Rectangle
@@ -324,45 +424,136 @@ type PageLink struct {
 	DestPoint image.Point
 }
 
+// Point is a location, in the units given by whatever returns it. Unlike image.Point, its coordinates are not rounded
+// to whole pixels.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Rect is an axis-aligned rectangle, in the units given by whatever returns it, which for most methods is
+// rendered-image pixel space and for PageInfo is points. Unlike image.Rectangle, its coordinates are not rounded to
+// whole pixels.
+type Rect struct {
+	Min Point
+	Max Point
+}
+
+// Width returns the width of the rectangle.
+func (r Rect) Width() float64 {
+	return r.Max.X - r.Min.X
+}
+
+// Height returns the height of the rectangle.
+func (r Rect) Height() float64 {
+	return r.Max.Y - r.Min.Y
+}
+
+// Quad is a four-cornered region in rendered-image pixel space. Unlike an image.Rectangle, it need not be axis-aligned,
+// so it can describe rotated or skewed text exactly.
+type Quad struct {
+	UL Point
+	UR Point
+	LL Point
+	LR Point
+}
+
+// Bounds returns the smallest image.Rectangle that encloses all four corners of the quad, expanded outward to whole
+// pixels.
+func (q Quad) Bounds() image.Rectangle {
+	return scaleRect(
+		math.Min(math.Min(q.UL.X, q.UR.X), math.Min(q.LL.X, q.LR.X)),
+		math.Min(math.Min(q.UL.Y, q.UR.Y), math.Min(q.LL.Y, q.LR.Y)),
+		math.Max(math.Max(q.UL.X, q.UR.X), math.Max(q.LL.X, q.LR.X)),
+		math.Max(math.Max(q.UL.Y, q.UR.Y), math.Max(q.LL.Y, q.LR.Y)),
+		1,
+	)
+}
+
 // RenderedPage holds the rendered page.
 type RenderedPage struct {
 	// Image is the rendered page. It is rendered with an alpha channel, and most PDF pages do not paint their own
 	// background, so areas with no content are transparent rather than white. Callers that want an opaque page (for
-	// example, when encoding to a format without alpha) should composite the image onto their desired background color.
-	Image      *image.NRGBA
+	// example, when encoding to a format without alpha) should composite the image onto their desired background color,
+	// or ask for one with RenderOptions. Image is nil when RenderOptions asks for output of another kind.
+	Image *image.NRGBA
+	// Output is the rendered page in the form RenderOptions asked for: an *image.NRGBA (the same as Image) for
+	// transparent RGB output, an *image.RGBA for opaque or premultiplied RGB output, an *image.Gray for grayscale
+	// output, or an *image.CMYK for CMYK output.
+	Output image.Image
+	// SearchHits holds the axis-aligned bounding box of each quad in Hits, in order.
 	SearchHits []image.Rectangle
-	Links      []*PageLink
+	// Hits holds the search matches, each with the exact quads it covers.
+	Hits  []*SearchHit
+	Links []*PageLink
+	// PageNumber is the number of the page that was rendered.
+	PageNumber int
 }
 
 // New returns new PDF document from the provided raw bytes. Pass in 0 for maxCacheSize for no limit.
 func New(buffer []byte, maxCacheSize uint64) (*Document, error) {
-	// Allow some garbage to be before the PDF content, as Acrobat and MuPDF itself allow it
-	if !bytes.Contains(buffer[:min(1024, len(buffer))], []byte("%PDF")) {
+	if !hasPDFHeader(buffer) {
 		return nil, ErrNotPDFData
 	}
-	d := Document{
-		document: &document{
-			ctx: C.wrapped_fz_new_context(nil, nil, C.size_t(maxCacheSize)),
-		},
+	return newDocument(maxCacheSize, pdfMIMEType, memoryStream(buffer))
+}
+
+// NewWithMIME returns a new document from the provided raw bytes, which may be in any of the formats MuPDF supports,
+// such as PDF, XPS, EPUB, FB2, CBZ, MOBI, SVG, or an image. mime is a MIME type (such as "application/epub+zip") or
+// file name extension (such as ".epub") that hints at the format; the content itself is also examined, so it may be
+// empty. ErrUnsupportedFormat is returned if the format is not recognized. Pass in 0 for maxCacheSize for no limit.
+//
+// Reflowable formats, such as EPUB, are laid out by MuPDF at a default page size.
+func NewWithMIME(buffer []byte, mime string, maxCacheSize uint64) (*Document, error) {
+	return newDocument(maxCacheSize, mime, memoryStream(buffer))
+}
+
+// memoryStream returns a function for newDocument that copies buffer into C memory owned by the document and opens a
+// stream on it.
+func memoryStream(buffer []byte) func(d *document) *C.fz_stream {
+	return func(d *document) *C.fz_stream {
+		if d.data = (*C.uchar)(C.CBytes(buffer)); d.data == nil {
+			return nil
+		}
+		d.dataSize = C.size_t(len(buffer))
+		return C.wrapped_fz_open_memory(d.ctx, d.data, d.dataSize)
 	}
-	if d.ctx == nil {
+}
+
+// hasPDFHeader returns true if the start of a document's data contains the PDF header.
+func hasPDFHeader(data []byte) bool {
+	// Allow some garbage to be before the PDF content, as Acrobat and MuPDF itself allow it
+	return bytes.Contains(data[:min(1024, len(data))], []byte("%PDF"))
+}
+
+// newDocument creates a context and opens a document in it from the stream returned by open, which is called once the
+// context is ready, using magic as a hint to the document's format. If open returns nil, ErrInternal is returned.
+func newDocument(maxCacheSize uint64, magic string, open func(d *document) *C.fz_stream) (*Document, error) {
+	d := Document{document: &document{locks: newLocks(), magic: magic}}
+	if d.locks == nil {
 		return nil, ErrUnableToCreatePDFContext
 	}
-	if C.wrapped_fz_register_document_handlers(d.ctx) == 0 {
+	if d.ctx = C.wrapped_fz_new_context(nil, d.locks, C.size_t(maxCacheSize)); d.ctx == nil {
 		d.Release()
 		return nil, ErrUnableToCreatePDFContext
 	}
-	d.data = (*C.uchar)(C.CBytes(buffer))
-	if d.data == nil {
+	if C.wrapped_fz_register_document_handlers(d.ctx) == 0 || !d.applyUserCSS() {
 		d.Release()
-		return nil, ErrInternal
+		return nil, ErrUnableToCreatePDFContext
 	}
-	stream := C.wrapped_fz_open_memory(d.ctx, d.data, C.size_t(len(buffer)))
+	stream := open(d.document)
 	if stream == nil {
 		d.Release()
 		return nil, ErrInternal
 	}
-	d.doc = C.wrapped_fz_open_pdf_document_with_stream(d.ctx, stream)
+	cMagic := C.CString(magic)
+	defer C.free(unsafe.Pointer(cMagic))
+	if magic != pdfMIMEType && C.wrapped_fz_recognize_document_stream_content(d.ctx, stream, cMagic) == 0 {
+		C.fz_drop_stream(d.ctx, stream)
+		d.Release()
+		return nil, ErrUnsupportedFormat
+	}
+	d.doc = C.wrapped_fz_open_document_with_stream(d.ctx, cMagic, stream)
 	C.fz_drop_stream(d.ctx, stream)
 	if d.doc == nil {
 		d.Release()
@@ -372,6 +563,32 @@ func New(buffer []byte, maxCacheSize uint64) (*Document, error) {
 	return &d, nil
 }
 
+// reopen opens the document again from its data in place of the current one, so that the style sheet of its context
+// takes effect, and lays it out as it last was by Layout. Only documents opened from memory can be opened again. The
+// caller must hold d.lock and must have dropped the loaded pages.
+func (d *document) reopen() error {
+	if d.data == nil {
+		return ErrUnableToOpenPDF
+	}
+	stream := C.wrapped_fz_open_memory(d.ctx, d.data, d.dataSize)
+	if stream == nil {
+		return ErrUnableToOpenPDF
+	}
+	cMagic := C.CString(d.magic)
+	defer C.free(unsafe.Pointer(cMagic))
+	doc := C.wrapped_fz_open_document_with_stream(d.ctx, cMagic, stream)
+	C.fz_drop_stream(d.ctx, stream)
+	if doc == nil {
+		return ErrUnableToOpenPDF
+	}
+	C.fz_drop_document(d.ctx, d.doc)
+	d.doc = doc
+	if d.layout != nil && !d.layOut(d.layout) {
+		return ErrUnableToLayout
+	}
+	return nil
+}
+
 // released reports whether the underlying document has been released. The caller must hold d.lock.
 func (d *document) released() bool {
 	return d.ctx == nil || d.doc == nil
@@ -411,20 +628,53 @@ func (d *Document) TableOfContents(dpi int) []*TOCEntry {
 		return nil
 	}
 	defer C.fz_drop_outline(d.ctx, outline)
-	entries, _ := buildTOCEntries(outline, float32(dpiToScale(dpi)), OverallMaxTOCEntries)
+	// The scale is rounded to a float32, as it always has been, so that the positions do not shift.
+	scale := float64(float32(dpiToScale(dpi)))
+	entries, _ := d.buildTOCEntries(outline, func(_ int, x, y C.float) image.Point {
+		return image.Pt(scaledFloor(float64(x), scale), scaledFloor(float64(y), scale))
+	}, OverallMaxTOCEntries)
+	return entries
+}
+
+// TableOfContentsWithOptions returns the table of contents for this document, if any, with the positions in the pixel
+// space of the pages rendered with opts, which may be nil for the defaults. Returns nil if opts holds an invalid
+// transform.
+func (d *Document) TableOfContentsWithOptions(opts *RenderOptions) []*TOCEntry {
+	m, err := opts.transform()
+	if err != nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil
+	}
+	outline := C.wrapped_fz_load_outline(d.ctx, d.doc)
+	if outline == nil {
+		return nil
+	}
+	defer C.fz_drop_outline(d.ctx, outline)
+	destFor := d.destTransform(m)
+	entries, _ := d.buildTOCEntries(outline, func(pageNumber int, x, y C.float) image.Point {
+		return destFor(pageNumber).dest(C.fz_point{x: x, y: y})
+	}, OverallMaxTOCEntries)
 	return entries
 }
 
-func buildTOCEntries(outline *C.fz_outline, scale float32, maxAllowed int) (entries []*TOCEntry, remaining int) {
+// buildTOCEntries converts the outline into entries, using dest to place the position of each in its page.
+func (d *Document) buildTOCEntries(outline *C.fz_outline, dest func(pageNumber int, x, y C.float) image.Point, maxAllowed int) (entries []*TOCEntry, remaining int) {
 	if maxAllowed < 1 {
 		return nil, 0
 	}
 	for outline != nil {
+		pageNumber := int(outline.page.page)
+		pt := dest(pageNumber, outline.x, outline.y)
 		entry := &TOCEntry{
-			PageNumber: int(outline.page.page),
-			PageX:      scaledFloor(float64(outline.x), float64(scale)),
-			PageY:      scaledFloor(float64(outline.y), float64(scale)),
+			PageNumber: pageNumber,
+			PageX:      pt.X,
+			PageY:      pt.Y,
 		}
+		entry.PageLabel = d.pageLabel(entry.PageNumber)
 		if outline.title != nil {
 			entry.Title = sanitizeString(outline.title)
 		}
@@ -434,7 +684,7 @@ func buildTOCEntries(outline *C.fz_outline, scale float32, maxAllowed int) (entr
 			break
 		}
 		if outline.down != nil {
-			if entry.Children, maxAllowed = buildTOCEntries(outline.down, scale, maxAllowed); maxAllowed <= 0 {
+			if entry.Children, maxAllowed = d.buildTOCEntries(outline.down, dest, maxAllowed); maxAllowed <= 0 {
 				break
 			}
 		}
@@ -444,9 +694,29 @@ func buildTOCEntries(outline *C.fz_outline, scale float32, maxAllowed int) (entr
 }
 
 func sanitizeString(in *C.char) string {
-	str := C.GoString(in)
+	return strings.TrimSpace(filterUnsafeRunes(C.GoString(in), nil))
+}
+
+// sanitizeText applies the same cleanup as sanitizeString to multi-line text, keeping the line breaks. Trailing
+// whitespace is removed from each line, as are leading and trailing blank lines. whitespace is passed along to
+// filterUnsafeRunes.
+func sanitizeText(str string, whitespace func(rune) rune) string {
+	lines := strings.Split(str, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(filterUnsafeRunes(line, whitespace), unicode.IsSpace)
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// filterUnsafeRunes removes the runes that are unsafe to display from str. If whitespace is not nil, each whitespace
+// rune, such as a tab or a no-break space, is replaced by what it returns rather than being removed.
+func filterUnsafeRunes(str string, whitespace func(rune) rune) string {
 	sanitized := make([]rune, 0, len(str))
 	for _, ch := range str {
+		if whitespace != nil && unicode.IsSpace(ch) {
+			sanitized = append(sanitized, whitespace(ch))
+			continue
+		}
 		// U+FFFD (the Unicode replacement character) stands in for bytes that could not be decoded as valid UTF-8,
 		// such as the unmappable dot-leader glyphs some PDFs place in outline titles. It is printable and non-control,
 		// so it would otherwise survive the filter below; drop it explicitly to keep those spurious characters out.
@@ -454,7 +724,7 @@ func sanitizeString(in *C.char) string {
 			sanitized = append(sanitized, ch)
 		}
 	}
-	return strings.TrimSpace(string(sanitized))
+	return string(sanitized)
 }
 
 // PageCount returns total number of pages in the document.
@@ -464,6 +734,12 @@ func (d *Document) PageCount() int {
 	if d.released() {
 		return 0
 	}
+	return d.pageCount()
+}
+
+// pageCount returns total number of pages in the document, or 0 if it cannot be determined. The caller must hold d.lock
+// and must have checked that the document has not been released.
+func (d *Document) pageCount() int {
 	if count := int(C.wrapped_fz_count_pages(d.ctx, d.doc)); count > 0 {
 		return count
 	}
@@ -475,26 +751,52 @@ func dpiToScale(dpi int) float64 {
 	return min(float64(max(dpi, 1))/72, 10)
 }
 
+func dpiToMatrix(dpi int) Matrix {
+	scale := dpiToScale(dpi)
+	return ScaleMatrix(scale, scale)
+}
+
 // RenderPage renders the specified page at the requested dpi. If search is not empty, then the bounding boxes of up to
 // maxHits matching text on the page will be returned.
 func (d *Document) RenderPage(pageNumber, dpi, maxHits int, search string) (*RenderedPage, error) {
-	return d.render(pageNumber, maxHits, search, func(*C.fz_page) (float64, error) {
-		return dpiToScale(dpi), nil
+	needle, opts := legacySearch(maxHits, search)
+	return d.RenderPageWithSearch(context.Background(), pageNumber, dpi, needle, opts)
+}
+
+// RenderPageWithSearch renders the specified page at the requested dpi. If needle is not empty, then the bounding boxes
+// of the text on the page that matches it, as controlled by opts, will be returned. If ctx is cancelled, the render is
+// abandoned and ctx.Err() is returned.
+func (d *Document) RenderPageWithSearch(ctx context.Context, pageNumber, dpi int, needle string, opts *SearchOptions) (*RenderedPage, error) {
+	return d.render(ctx, pageNumber, needle, opts, func(*C.fz_page) (Matrix, error) {
+		return dpiToMatrix(dpi), nil
 	})
 }
 
 // RenderPageForSize renders the specified page to fit within the requested size. If search is not empty, then the
 // bounding boxes of up to maxHits matching text on the page will be returned.
 func (d *Document) RenderPageForSize(pageNumber, maxWidth, maxHeight, maxHits int, search string) (*RenderedPage, error) {
-	return d.render(pageNumber, maxHits, search, func(page *C.fz_page) (float64, error) {
+	needle, opts := legacySearch(maxHits, search)
+	return d.RenderPageForSizeWithSearch(context.Background(), pageNumber, maxWidth, maxHeight, needle, opts)
+}
+
+// RenderPageForSizeWithSearch renders the specified page to fit within the requested size. If needle is not empty,
+// then the bounding boxes of the text on the page that matches it, as controlled by opts, will be returned. If ctx is
+// cancelled, the render is abandoned and ctx.Err() is returned.
+func (d *Document) RenderPageForSizeWithSearch(ctx context.Context, pageNumber, maxWidth, maxHeight int, needle string, opts *SearchOptions) (*RenderedPage, error) {
+	return d.render(ctx, pageNumber, needle, opts, d.scaleToFit(maxWidth, maxHeight))
+}
+
+// scaleToFit returns a transformFor function for render that fits the page within maxWidth×maxHeight.
+func (d *Document) scaleToFit(maxWidth, maxHeight int) func(page *C.fz_page) (Matrix, error) {
+	return func(page *C.fz_page) (Matrix, error) {
 		if maxWidth <= 0 || maxHeight <= 0 {
-			return 0, ErrInvalidPageSize
+			return Matrix{}, ErrInvalidPageSize
 		}
 		r := C.wrapped_fz_bound_page(d.ctx, page)
 		w := float64(r.x1 - r.x0)
 		h := float64(r.y1 - r.y0)
 		if w <= 0 || h <= 0 {
-			return 0, ErrInvalidPageSize
+			return Matrix{}, ErrInvalidPageSize
 		}
 		scale := float64(maxWidth) / w
 		if ratio := float64(maxHeight) / h; ratio < scale {
@@ -504,93 +806,259 @@ func (d *Document) RenderPageForSize(pageNumber, maxWidth, maxHeight, maxHits in
 		// requested box. Reject an over-large request here, before building the display list or asking MuPDF to
 		// allocate the pixmap.
 		if (w*scale)*(h*scale) > float64(OverallMaxPixels) {
-			return 0, ErrImageTooLarge
+			return Matrix{}, ErrImageTooLarge
 		}
-		return scale, nil
+		return ScaleMatrix(scale, scale), nil
+	}
+}
+
+// legacySearch converts the maxHits and search arguments of RenderPage and RenderPageForSize into their
+// RenderPageWithSearch and RenderPageForSizeWithSearch equivalents. A maxHits of 0 or less means no search, whereas a
+// MaxHits option of 0 or less means OverallMaxHits.
+func legacySearch(maxHits int, search string) (needle string, opts *SearchOptions) {
+	if maxHits <= 0 {
+		return "", nil
+	}
+	return search, &SearchOptions{MaxHits: maxHits}
+}
+
+// render is the shared body of the RenderPage variants. Everything but the rasterization of the page is done by
+// prepareRender while holding the document lock; the rasterization, which is usually the bulk of the work, is done
+// outside of it, so renders of different pages of the same document can run in parallel.
+func (d *Document) render(ctx context.Context, pageNumber int, needle string, opts *SearchOptions, transformFor func(page *C.fz_page) (Matrix, error)) (*RenderedPage, error) {
+	s, err := newSearcher(needle, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.renderPage(ctx, pageNumber, s, opts.maxHits(), transformFor, nil)
+}
+
+// renderPage renders the page with an already prepared searcher, producing the output described by renderOpts, which
+// may be nil for the defaults. The render is abandoned if ctx is cancelled.
+func (d *Document) renderPage(ctx context.Context, pageNumber int, s *searcher, maxHits int, transformFor func(page *C.fz_page) (Matrix, error), renderOpts *RenderOptions) (*RenderedPage, error) {
+	return d.runRender(ctx, renderOpts, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		return d.prepareRender(pageNumber, s, maxHits, transformFor, c)
 	})
 }
 
-// render is the shared body of RenderPage and RenderPageForSize. It validates the page number, loads the page, asks
-// scaleFor to compute the render scale (which may inspect the page bounds and reject the request), builds the display
-// list, renders, and assembles the result. The document lock is held throughout so the underlying C calls are
-// serialized.
-func (d *Document) render(pageNumber, maxHits int, search string, scaleFor func(page *C.fz_page) (float64, error)) (*RenderedPage, error) {
+// runRender calls prepare and then rasterizes the job it returns into the output described by renderOpts, which may be
+// nil for the defaults, abandoning the render if ctx is cancelled.
+func (d *Document) runRender(ctx context.Context, renderOpts *RenderOptions, prepare func(c *cookie) (*RenderedPage, *renderJob, error)) (*RenderedPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	rendered, job, err := prepare(c)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	// If ctx was cancelled in the meantime, the cookie has already been aborted, so this returns promptly.
+	job.options = renderOpts
+	rendered.Output, err = d.rasterize(job, c)
+	if err = c.check(err); err != nil {
+		return nil, err
+	}
+	rendered.Image, _ = rendered.Output.(*image.NRGBA)
+	return rendered, nil
+}
+
+// renderJob holds what rasterize needs to render a page outside of the document lock.
+type renderJob struct {
+	ctx         *C.fz_context
+	displayList *C.fz_display_list
+	// options describes the output to produce. May be nil for the defaults.
+	options *RenderOptions
+	// dst, if not nil, is the image to render into, which must be the size of bbox. Otherwise, a new image is made.
+	dst image.Image
+	ctm C.fz_matrix
+	// bbox is the area to render, in pixels. It covers the whole page unless the job is for a tile.
+	bbox C.fz_irect
+}
+
+// prepareRender validates the page number, loads the page, asks transformFor to compute the render transform (which may
+// inspect the page bounds and reject the request), builds the display list, and prepares the job with prepareJob, all
+// while holding the document lock.
+func (d *Document) prepareRender(pageNumber int, s *searcher, maxHits int, transformFor func(page *C.fz_page) (Matrix, error), c *cookie) (*RenderedPage, *renderJob, error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	if d.released() {
-		return nil, ErrDocumentReleased
-	}
-	pageCount := int(C.wrapped_fz_count_pages(d.ctx, d.doc))
-	if pageNumber < 0 || pageNumber >= pageCount {
-		return nil, ErrInvalidPageNumber
+		return nil, nil, ErrDocumentReleased
 	}
-	page := C.wrapped_fz_load_page(d.ctx, d.doc, C.int(pageNumber))
-	if page == nil {
-		return nil, ErrUnableToLoadPage
+	page, err := d.loadPage(pageNumber)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer C.fz_drop_page(d.ctx, page)
-	scale, err := scaleFor(page)
+	m, err := transformFor(page)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	displayList := C.wrapped_fz_new_display_list_from_page(d.ctx, page)
+	displayList := d.newDisplayList(page, c)
 	if displayList == nil {
-		return nil, ErrUnableToCreateImage
+		return nil, nil, ErrUnableToCreateImage
 	}
 	defer C.fz_drop_display_list(d.ctx, displayList)
-	img, err := d.renderPage(displayList, scale)
+	return d.prepareJob(displayList, d.resolveLinks(page), pageNumber, m, s, maxHits, c)
+}
+
+// prepareJob fits m to the page, finds the search hits in the display list and transforms them and the links, then sets
+// up a job for rasterize with its own reference to the display list and its own clone of the context. On success, the
+// returned job must be passed to rasterize. The caller must hold d.lock and must have checked that the document has not
+// been released.
+func (d *Document) prepareJob(displayList *C.fz_display_list, links []resolvedLink, pageNumber int, m Matrix, s *searcher, maxHits int, c *cookie) (*RenderedPage, *renderJob, error) {
+	if !m.valid() {
+		return nil, nil, ErrInvalidTransform
+	}
+	bounds := C.fz_bound_display_list(d.ctx, displayList)
+	fitted := m.fitTo(bounds)
+	boxes, hits, err := d.searchDisplayList(displayList, fitted, s, maxHits, c)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	clone := d.cloneContext()
+	if clone == nil {
+		return nil, nil, ErrUnableToCreatePDFContext
 	}
 	return &RenderedPage{
-		Image:      img,
-		SearchHits: d.searchDisplayList(displayList, scale, search, maxHits),
-		Links:      d.loadLinks(page, scale),
-	}, nil
+		SearchHits: boxes,
+		Hits:       hits,
+		Links:      transformLinks(links, fitted, d.destTransform(m)),
+		PageNumber: pageNumber,
+	}, newRenderJob(clone, C.fz_keep_display_list(d.ctx, displayList), fitted, bounds), nil
 }
 
-func (d *Document) renderPage(displayList *C.fz_display_list, scale float64) (*image.NRGBA, error) {
-	ctm := C.fz_scale(C.float(scale), C.float(scale))
-	cs := C.fz_device_rgb(d.ctx)
-	pixmap := C.wrapped_fz_new_pixmap_from_display_list(d.ctx, displayList, ctm, cs, 1)
-	if pixmap == nil {
-		return nil, ErrUnableToCreateImage
+// rasterize renders the display list of a job from prepareRender using the job's clone of the document's context, so
+// it does not need the document lock. The job is finished with afterward.
+func (d *Document) rasterize(job *renderJob, c *cookie) (image.Image, error) {
+	defer d.dropClone(job.ctx)
+	defer C.fz_drop_display_list(job.ctx, job.displayList)
+	// The clone has its own copy of the anti-aliasing settings, so changing them does not affect other renders.
+	job.options.applyAntiAliasing(job.ctx)
+	if job.dst == nil {
+		return renderDisplayList(job.ctx, job.displayList, job.ctm, job.bbox, job.options, c)
+	}
+	if err := drawDisplayList(job.ctx, job.displayList, job.ctm, job.bbox, job.dst, job.options, c); err != nil {
+		return nil, err
 	}
-	defer C.fz_drop_pixmap(d.ctx, pixmap)
-	if pixmap.w <= 0 || pixmap.h <= 0 {
-		return nil, ErrUnableToCreateImage
+	return job.dst, nil
+}
+
+// newRenderJob returns a job that renders the whole of the display list, whose bounds are given, transformed by m.
+func newRenderJob(ctx *C.fz_context, displayList *C.fz_display_list, m Matrix, bounds C.fz_rect) *renderJob {
+	ctm := m.fz()
+	return &renderJob{
+		ctx:         ctx,
+		displayList: displayList,
+		ctm:         ctm,
+		bbox:        C.fz_round_rect(C.fz_transform_rect(bounds, ctm)),
 	}
-	if int64(pixmap.w)*int64(pixmap.h) > int64(OverallMaxPixels) {
-		return nil, ErrImageTooLarge
+}
+
+// loadPage validates the page number and loads the page. The caller must hold d.lock, must have checked that the
+// document has not been released, and must drop the returned page.
+func (d *Document) loadPage(pageNumber int) (*C.fz_page, error) {
+	if pageNumber < 0 || pageNumber >= d.pageCount() {
+		return nil, ErrInvalidPageNumber
 	}
-	pixels := C.fz_pixmap_samples(d.ctx, pixmap)
-	if pixels == nil {
-		return nil, ErrUnableToCreateImage
+	page := C.wrapped_fz_load_page(d.ctx, d.doc, C.int(pageNumber))
+	if page == nil {
+		return nil, ErrUnableToLoadPage
 	}
-	size := int(pixmap.stride) * int(pixmap.h)
-	if size <= 0 {
+	return page, nil
+}
+
+// newDisplayList builds the display list for a loaded page, returning nil if that fails or is aborted through c, which
+// may be nil. The caller must hold d.lock and must drop the returned list.
+func (d *Document) newDisplayList(page *C.fz_page, c *cookie) *C.fz_display_list {
+	return C.wrapped_fz_new_display_list_from_page(d.ctx, page, c.fz())
+}
+
+// renderDisplayList rasterizes the part of the display list, transformed by ctm, that falls within bbox into a new image
+// described by opts using ctx, which must not be in use by any other goroutine.
+func renderDisplayList(ctx *C.fz_context, displayList *C.fz_display_list, ctm C.fz_matrix, bbox C.fz_irect, opts *RenderOptions, c *cookie) (image.Image, error) {
+	width := int64(bbox.x1) - int64(bbox.x0)
+	height := int64(bbox.y1) - int64(bbox.y0)
+	if width <= 0 || height <= 0 {
 		return nil, ErrUnableToCreateImage
 	}
-	if size > math.MaxInt32 {
+	if width*height > int64(OverallMaxPixels) {
 		return nil, ErrImageTooLarge
 	}
-	pix := C.GoBytes(unsafe.Pointer(pixels), C.int(size))
-	// MuPDF renders with premultiplied alpha, but image.NRGBA expects non-premultiplied (straight) alpha, so undo the
-	// premultiplication. Fully opaque (a == 255) and fully transparent (a == 0) pixels need no adjustment.
-	for i := 0; i+3 < len(pix); i += 4 {
-		switch a := pix[i+3]; a {
-		case 0, 255:
-		default:
-			pix[i] = unpremultiply(pix[i], a)
-			pix[i+1] = unpremultiply(pix[i+1], a)
-			pix[i+2] = unpremultiply(pix[i+2], a)
+	img := opts.newImage(image.Rect(0, 0, int(width), int(height)))
+	if err := drawDisplayList(ctx, displayList, ctm, bbox, img, opts, c); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// drawDisplayList rasterizes the part of the display list, transformed by ctm, that falls within bbox straight into the
+// pixels of img, which must be the size of bbox and of a type that matches opts, using ctx, which must not be in use by
+// any other goroutine.
+func drawDisplayList(ctx *C.fz_context, displayList *C.fz_display_list, ctm C.fz_matrix, bbox C.fz_irect, img image.Image, opts *RenderOptions, c *cookie) error {
+	pix, stride, _, err := imageSamples(img)
+	if err != nil {
+		return err
+	}
+	var alpha, transparent C.int
+	switch img.(type) {
+	case *image.NRGBA, *image.RGBA:
+		// RGB output always has an alpha channel, so that it can be used as is. When opaque, the background fills
+		// it, which makes every pixel fully opaque.
+		alpha = 1
+	}
+	if opts.transparent() {
+		transparent = 1
+	}
+	background := opts.background()
+	if C.wrapped_render_display_list(ctx, displayList, ctm, bbox, opts.colorSpace().fz(ctx), alpha, transparent,
+		&background[0], (*C.uchar)(unsafe.Pointer(&pix[0])), C.int(stride), c.fz()) == 0 {
+		return ErrUnableToCreateImage
+	}
+	if nrgba, ok := img.(*image.NRGBA); ok && transparent != 0 {
+		unpremultiplyNRGBA(nrgba)
+	}
+	return nil
+}
+
+// imageSamples returns the pixels of img from its top-left corner on, along with their stride and the number of bytes in
+// each pixel, checking that they hold the whole of img.
+func imageSamples(img image.Image) (pix []byte, stride, n int, err error) {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		pix, stride, n = img.Pix[min(img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y), len(img.Pix)):], img.Stride, 4
+	case *image.RGBA:
+		pix, stride, n = img.Pix[min(img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y), len(img.Pix)):], img.Stride, 4
+	case *image.Gray:
+		pix, stride, n = img.Pix[min(img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y), len(img.Pix)):], img.Stride, 1
+	case *image.CMYK:
+		pix, stride, n = img.Pix[min(img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y), len(img.Pix)):], img.Stride, 4
+	default:
+		return nil, 0, 0, ErrUnsupportedImage
+	}
+	bounds := img.Bounds()
+	if bounds.Empty() || bounds.Dx() > math.MaxInt32 || bounds.Dy() > math.MaxInt32 || stride < bounds.Dx()*n ||
+		stride > math.MaxInt32 || len(pix) < (bounds.Dy()-1)*stride+bounds.Dx()*n {
+		return nil, 0, 0, ErrUnsupportedImage
+	}
+	return pix, stride, n, nil
+}
+
+// unpremultiplyNRGBA converts the premultiplied pixels MuPDF renders into the non-premultiplied (straight) alpha that
+// image.NRGBA expects. Fully opaque (a == 255) and fully transparent (a == 0) pixels need no adjustment.
+func unpremultiplyNRGBA(img *image.NRGBA) {
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):][:img.Rect.Dx()*4]
+		for i := 0; i+3 < len(row); i += 4 {
+			switch a := row[i+3]; a {
+			case 0, 255:
+			default:
+				row[i] = unpremultiply(row[i], a)
+				row[i+1] = unpremultiply(row[i+1], a)
+				row[i+2] = unpremultiply(row[i+2], a)
+			}
 		}
 	}
-	return &image.NRGBA{
-		Pix:    pix,
-		Stride: int(pixmap.stride),
-		Rect:   image.Rect(0, 0, int(pixmap.w), int(pixmap.h)),
-	}, nil
 }
 
 // unpremultiply converts a single premultiplied color component back to its straight-alpha value, rounding to nearest
@@ -603,31 +1071,63 @@ func unpremultiply(c, a uint8) uint8 {
 	return uint8(v)
 }
 
-func (d *Document) searchDisplayList(displayList *C.fz_display_list, scale float64, search string, maxHits int) []image.Rectangle {
-	var boxes []image.Rectangle
-	if search != "" && maxHits > 0 && OverallMaxHits > 0 {
-		searchText := C.CString(search)
-		defer C.free(unsafe.Pointer(searchText))
-		quads := make([]C.fz_quad, min(maxHits, OverallMaxHits))
-		hits := C.wrapped_fz_search_display_list(d.ctx, displayList, searchText, nil, (*C.fz_quad)(unsafe.Pointer(&quads[0])), C.int(len(quads)))
-		if hits > 0 {
-			boxes = make([]image.Rectangle, hits)
-			for i := range boxes {
-				boxes[i] = quadToRect(quads[i], scale)
-			}
+func (d *Document) searchDisplayList(displayList *C.fz_display_list, m Matrix, s *searcher, maxHits int, c *cookie) (boxes []image.Rectangle, hits []*SearchHit, err error) {
+	var matches [][]C.fz_quad
+	if matches, err = d.search(displayList, s, maxHits, c); err != nil {
+		return nil, nil, err
+	}
+	for _, match := range matches {
+		for _, q := range match {
+			boxes = append(boxes, m.quadBounds(q))
+		}
+	}
+	return boxes, transformMatches(matches, m, 0), nil
+}
+
+// searchDisplayListQuads returns up to maxHits (capped by OverallMaxHits) unscaled quads of the text matching search
+// in the display list, grouped by match. Only whole matches are returned. The caller must hold d.lock.
+func (d *Document) searchDisplayListQuads(displayList *C.fz_display_list, search string, maxHits int) [][]C.fz_quad {
+	if search == "" || maxHits <= 0 || OverallMaxHits <= 0 {
+		return nil
+	}
+	searchText := C.CString(search)
+	defer C.free(unsafe.Pointer(searchText))
+	// Room is left for one more quad than may be returned, so that a buffer that fills up shows that there was more.
+	limit := min(maxHits, OverallMaxHits)
+	quads := make([]C.fz_quad, limit+1)
+	marks := make([]C.int, len(quads))
+	hits := C.wrapped_fz_search_display_list(d.ctx, displayList, searchText, (*C.int)(unsafe.Pointer(&marks[0])), (*C.fz_quad)(unsafe.Pointer(&quads[0])), C.int(len(quads)))
+	if hits <= 0 {
+		return nil
+	}
+	// MuPDF marks the first quad of each match with a non-zero value and any further quads of the same match, such as
+	// those for a phrase that wraps onto the next line, with zero.
+	var matches [][]C.fz_quad
+	start := 0
+	for i := 1; i <= int(hits); i++ {
+		if i == int(hits) || marks[i] != 0 {
+			matches = append(matches, quads[start:i])
+			start = i
 		}
 	}
-	return boxes
+	// When the buffer filled up, MuPDF stopped partway through the search, so the last match may be missing some of its
+	// quads. As it holds the quad beyond the limit, it is dropped, the same as limitMatches does for a match that
+	// would go past it.
+	if int(hits) > limit {
+		matches = matches[:len(matches)-1]
+	}
+	return matches
 }
 
-// quadToRect computes the scaled, axis-aligned bounding rectangle that encloses all four corners of a search-hit quad.
-// Considering every corner (rather than assuming an axis-aligned quad) keeps the box correct for rotated or skewed text.
-func quadToRect(q C.fz_quad, scale float64) image.Rectangle {
-	minX := math.Min(math.Min(float64(q.ul.x), float64(q.ur.x)), math.Min(float64(q.ll.x), float64(q.lr.x)))
-	minY := math.Min(math.Min(float64(q.ul.y), float64(q.ur.y)), math.Min(float64(q.ll.y), float64(q.lr.y)))
-	maxX := math.Max(math.Max(float64(q.ul.x), float64(q.ur.x)), math.Max(float64(q.ll.x), float64(q.lr.x)))
-	maxY := math.Max(math.Max(float64(q.ul.y), float64(q.ur.y)), math.Max(float64(q.ll.y), float64(q.lr.y)))
-	return scaleRect(minX, minY, maxX, maxY, scale)
+// scaleQuad scales a MuPDF quad by scale into rendered-image pixel space. No rounding is applied, so the corners keep
+// their exact positions.
+func scaleQuad(q C.fz_quad, scale float64) Quad {
+	return Quad{
+		UL: Point{X: float64(q.ul.x) * scale, Y: float64(q.ul.y) * scale},
+		UR: Point{X: float64(q.ur.x) * scale, Y: float64(q.ur.y) * scale},
+		LL: Point{X: float64(q.ll.x) * scale, Y: float64(q.ll.y) * scale},
+		LR: Point{X: float64(q.lr.x) * scale, Y: float64(q.lr.y) * scale},
+	}
 }
 
 // scaleRect scales an axis-aligned rectangle by scale and converts it to integer pixel space, expanding outward so the
@@ -641,6 +1141,11 @@ func scaleRect(x0, y0, x1, y1, scale float64) image.Rectangle {
 	)
 }
 
+// rectToImage scales a MuPDF rect by scale into rendered-image pixel space, expanding outward as scaleRect does.
+func rectToImage(r C.fz_rect, scale float64) image.Rectangle {
+	return scaleRect(float64(r.x0), float64(r.y0), float64(r.x1), float64(r.y1), scale)
+}
+
 // scaledFloor multiplies v by scale, floors the result, and converts it to an int. MuPDF reports a destination that
 // carries no explicit coordinate (e.g. a /Fit destination, in both link targets and TOC entries) as a non-finite
 // value; Go's conversion of a non-finite (or out-of-range) float to int is architecture-defined — 0 on arm64 but
@@ -655,34 +1160,46 @@ func scaledFloor(v, scale float64) int {
 }
 
 func (d *Document) loadLinks(page *C.fz_page, scale float64) []*PageLink {
+	return scaleLinks(d.resolveLinks(page), scale)
+}
+
+// resolvedLink is a link of a page with its target resolved, in unscaled MuPDF coordinates.
+type resolvedLink struct {
+	uri        string
+	label      string
+	rect       C.fz_rect
+	dest       C.fz_point
+	pageNumber int
+}
+
+// resolveLinks returns up to OverallMaxLinks links of the page with their targets resolved. The caller must hold d.lock
+// and must have checked that the document has not been released.
+func (d *Document) resolveLinks(page *C.fz_page) []resolvedLink {
 	if OverallMaxLinks < 1 {
 		return nil
 	}
-	var links []*PageLink
+	var links []resolvedLink
 	if link := C.wrapped_fz_load_links(d.ctx, page); link != nil {
 		firstLink := link
 		for link != nil {
-			pageLink := &PageLink{
-				PageNumber: -1,
-				Bounds: scaleRect(float64(link.rect.x0), float64(link.rect.y0),
-					float64(link.rect.x1), float64(link.rect.y1), scale),
+			resolved := resolvedLink{
+				rect:       link.rect,
+				pageNumber: -1,
 			}
 			// External links keep their URI; internal links are resolved to a page. fz_resolve_link returns the
 			// target location and fz_page_number_from_location turns it into MuPDF's 0-based page number, which is the
 			// same numbering fz_load_page and this package's API use, so no adjustment is needed. Internal links that
 			// cannot be resolved come back as -1 and, with an empty URI, are dropped by the test below.
 			if C.wrapped_fz_is_external_link(d.ctx, link.uri) != 0 {
-				pageLink.URI = sanitizeString(link.uri)
+				resolved.uri = sanitizeString(link.uri)
 			} else {
 				res := C.wrapped_fz_resolve_link(d.ctx, d.doc, link.uri)
-				pageLink.PageNumber = int(res.page)
-				pageLink.DestPoint = image.Pt(
-					scaledFloor(float64(res.x), scale),
-					scaledFloor(float64(res.y), scale),
-				)
+				resolved.pageNumber = int(res.page)
+				resolved.label = d.pageLabel(resolved.pageNumber)
+				resolved.dest = C.fz_point{x: res.x, y: res.y}
 			}
-			if pageLink.PageNumber >= 0 || pageLink.URI != "" {
-				if links = append(links, pageLink); len(links) >= OverallMaxLinks {
+			if resolved.pageNumber >= 0 || resolved.uri != "" {
+				if links = append(links, resolved); len(links) >= OverallMaxLinks {
 					break
 				}
 			}
@@ -693,6 +1210,35 @@ func (d *Document) loadLinks(page *C.fz_page, scale float64) []*PageLink {
 	return links
 }
 
+// scaleLinks converts resolved links into PageLinks in the pixel space of the page rendered at scale.
+func scaleLinks(links []resolvedLink, scale float64) []*PageLink {
+	m := ScaleMatrix(scale, scale)
+	return transformLinks(links, m, func(int) Matrix { return m })
+}
+
+// destTransform returns a function that gives the transform, fitted to the page, for the destinations on each page
+// that is rendered with m. When m only scales, every page uses m itself. Otherwise, the bounds of each page are needed,
+// so each page is loaded once, when it is first asked for. The caller must hold d.lock and must have checked that the
+// document has not been released.
+func (d *Document) destTransform(m Matrix) func(pageNumber int) Matrix {
+	if m.isScale() {
+		return func(int) Matrix { return m }
+	}
+	fitted := make(map[int]Matrix)
+	return func(pageNumber int) Matrix {
+		if f, ok := fitted[pageNumber]; ok {
+			return f
+		}
+		f := m
+		if page, err := d.loadPage(pageNumber); err == nil {
+			f = m.fitTo(C.wrapped_fz_bound_page(d.ctx, page))
+			C.fz_drop_page(d.ctx, page)
+		}
+		fitted[pageNumber] = f
+		return f
+	}
+}
+
 // Release the underlying PDF document, releasing any resources. It is not necessary to call this, as garbage collection
 // will eventually do this for you, however, doing so explicitly will cause an immediate reclamation of any used memory.
 func (d *Document) Release() {
@@ -702,6 +1248,11 @@ func (d *Document) Release() {
 func (d *document) release() {
 	d.lock.Lock()
 	defer d.lock.Unlock()
+	// Renders that have moved outside of the lock still share the context, so wait for them to finish.
+	d.inflight.Wait()
+	for p := range d.pages {
+		p.drop()
+	}
 	if d.doc != nil {
 		C.fz_drop_document(d.ctx, d.doc)
 		d.doc = nil
@@ -714,4 +1265,8 @@ func (d *document) release() {
 		C.fz_drop_context(d.ctx)
 		d.ctx = nil
 	}
+	if d.locks != nil {
+		dropLocks(d.locks)
+		d.locks = nil
+	}
 }
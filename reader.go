@@ -0,0 +1,157 @@
+package pdf
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <mupdf/fitz.h>
+
+// Defined in reader_export.go.
+int64_t pdfReaderReadAt(uintptr_t handle, unsigned char *buf, size_t length, int64_t offset);
+void pdfReaderRelease(uintptr_t handle);
+
+fz_stream *wrapped_fz_open_file(fz_context *ctx, const char *filename) {
+	fz_stream *stream = NULL;
+	fz_var(stream);
+	fz_try(ctx) {
+		stream = fz_open_file(ctx, filename);
+	}
+	fz_catch(ctx) {
+		stream = NULL;
+	}
+	return stream;
+}
+
+// The state of a stream that pulls its bytes on demand from a Go io.ReaderAt, identified by a cgo.Handle.
+typedef struct {
+	uintptr_t handle;
+	int64_t size;
+	unsigned char buffer[16384];
+} reader_state;
+
+static int next_reader(fz_context *ctx, fz_stream *stm, size_t max) {
+	reader_state *state = stm->state;
+	int64_t n = 0;
+	if (max > sizeof(state->buffer)) {
+		max = sizeof(state->buffer);
+	}
+	if (stm->pos < state->size) {
+		if ((int64_t)max > state->size - stm->pos) {
+			max = (size_t)(state->size - stm->pos);
+		}
+		n = pdfReaderReadAt(state->handle, state->buffer, max, stm->pos);
+		if (n < 0) {
+			fz_throw(ctx, FZ_ERROR_SYSTEM, "read error");
+		}
+	}
+	stm->rp = state->buffer;
+	stm->wp = state->buffer + n;
+	stm->pos += n;
+	if (n == 0) {
+		return EOF;
+	}
+	return *stm->rp++;
+}
+
+static void seek_reader(fz_context *ctx, fz_stream *stm, int64_t offset, int whence) {
+	reader_state *state = stm->state;
+	if (whence == SEEK_END) {
+		offset += state->size;
+	} else if (whence == SEEK_CUR) {
+		offset += stm->pos;
+	}
+	if (offset < 0) {
+		offset = 0;
+	} else if (offset > state->size) {
+		offset = state->size;
+	}
+	stm->pos = offset;
+	stm->rp = state->buffer;
+	stm->wp = state->buffer;
+}
+
+static void drop_reader(fz_context *ctx, void *state) {
+	pdfReaderRelease(((reader_state *)state)->handle);
+	fz_free(ctx, state);
+}
+
+// Returns a stream that reads size bytes through the Go io.ReaderAt identified by handle, or NULL if it threw. The
+// stream takes ownership of the handle, which is released once the stream is dropped or, if this fails, before
+// returning.
+fz_stream *wrapped_open_reader(fz_context *ctx, uintptr_t handle, int64_t size) {
+	reader_state *state = NULL;
+	fz_stream *stream = NULL;
+	fz_var(state);
+	fz_var(stream);
+	fz_try(ctx) {
+		state = fz_malloc_struct(ctx, reader_state);
+		state->handle = handle;
+		state->size = size;
+		// If this throws, it drops the state, which releases the handle.
+		stream = fz_new_stream(ctx, state, next_reader, drop_reader);
+		stream->seek = seek_reader;
+	}
+	fz_catch(ctx) {
+		if (state == NULL) {
+			pdfReaderRelease(handle);
+		}
+		stream = NULL;
+	}
+	return stream;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// Open returns a new PDF document read from the file at path. Unlike New, the file is not loaded into memory up front;
+// its bytes are read as they are needed, so memory use is governed by maxCacheSize rather than by the size of the file.
+// Pass in 0 for maxCacheSize for no limit. The file must not be modified while the document is in use.
+func Open(path string, maxCacheSize uint64) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 1024)
+	n, err := io.ReadFull(f, header)
+	if closeErr := f.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !hasPDFHeader(header[:n]) {
+		return nil, ErrNotPDFData
+	}
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	return newDocument(maxCacheSize, pdfMIMEType, func(d *document) *C.fz_stream {
+		return C.wrapped_fz_open_file(d.ctx, cPath)
+	})
+}
+
+// NewFromReaderAt returns a new PDF document read from the first size bytes of r. Unlike New, the data is not copied up
+// front; it is read from r as it is needed, so memory use is governed by maxCacheSize rather than by the size of the
+// data. Pass in 0 for maxCacheSize for no limit. r is only called while a method of the document is running, one call
+// at a time, and must keep returning the same data for as long as the document is in use.
+func NewFromReaderAt(r io.ReaderAt, size int64, maxCacheSize uint64) (*Document, error) {
+	if size < 0 {
+		return nil, ErrNotPDFData
+	}
+	header := make([]byte, min(size, 1024))
+	n, err := r.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !hasPDFHeader(header[:n]) {
+		return nil, ErrNotPDFData
+	}
+	return newDocument(maxCacheSize, pdfMIMEType, func(d *document) *C.fz_stream {
+		return C.wrapped_open_reader(d.ctx, C.uintptr_t(cgo.NewHandle(r)), C.int64_t(size))
+	})
+}
@@ -0,0 +1,43 @@
+package pdf
+
+/*
+#include <stdint.h>
+#include <stddef.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// pdfReaderReadAt fills buf with up to length bytes read at offset from the io.ReaderAt identified by handle, returning
+// the number of bytes read, or -1 if the read failed.
+//
+//export pdfReaderReadAt
+func pdfReaderReadAt(handle C.uintptr_t, buf *C.uchar, length C.size_t, offset C.int64_t) (n C.int64_t) {
+	defer func() {
+		// A panic must not unwind through the C frames of MuPDF.
+		if recover() != nil {
+			n = -1
+		}
+	}()
+	r, ok := cgo.Handle(handle).Value().(io.ReaderAt)
+	if !ok {
+		return -1
+	}
+	count, err := r.ReadAt(unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(length)), int64(offset))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return -1
+	}
+	return C.int64_t(count)
+}
+
+// pdfReaderRelease releases the handle of an io.ReaderAt once its stream no longer needs it.
+//
+//export pdfReaderRelease
+func pdfReaderRelease(handle C.uintptr_t) {
+	cgo.Handle(handle).Delete()
+}
@@ -0,0 +1,91 @@
+package pdf_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+const testFile = "testfiles/GLAIVE_Mini_v2_3_for_GURPS_4e.pdf"
+
+func TestOpen(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := pdf.New(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer want.Release()
+	expected, err := want.RenderPage(0, 72, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := pdf.Open(testFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fromFile.Release()
+	fromReader, err := pdf.NewFromReaderAt(bytes.NewReader(data), int64(len(data)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fromReader.Release()
+	for name, doc := range map[string]*pdf.Document{"Open": fromFile, "NewFromReaderAt": fromReader} {
+		if doc.PageCount() != want.PageCount() {
+			t.Errorf("%s: expected %d pages, got %d", name, want.PageCount(), doc.PageCount())
+		}
+		page, renderErr := doc.RenderPage(0, 72, 0, "")
+		if renderErr != nil {
+			t.Fatalf("%s: %v", name, renderErr)
+		}
+		if !bytes.Equal(page.Image.Pix, expected.Image.Pix) {
+			t.Errorf("%s: expected the same rendering as New", name)
+		}
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	if _, err := pdf.Open(filepath.Join(t.TempDir(), "missing.pdf"), 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+	notPDF := filepath.Join(t.TempDir(), "not.pdf")
+	if err := os.WriteFile(notPDF, []byte("not a pdf"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pdf.Open(notPDF, 0); !errors.Is(err, pdf.ErrNotPDFData) {
+		t.Errorf("expected ErrNotPDFData for a non-PDF file, got %v", err)
+	}
+	if _, err := pdf.NewFromReaderAt(bytes.NewReader([]byte("not a pdf")), 9, 0); !errors.Is(err, pdf.ErrNotPDFData) {
+		t.Errorf("expected ErrNotPDFData for non-PDF data, got %v", err)
+	}
+
+	// A reader that fails after its header must not take the process down.
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pdf.NewFromReaderAt(&failingReader{data: data[:1024]}, int64(len(data)), 0); !errors.Is(err, pdf.ErrUnableToOpenPDF) {
+		t.Errorf("expected ErrUnableToOpenPDF from a failing reader, got %v", err)
+	}
+}
+
+// failingReader returns its data, then fails every read beyond it.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(r.data)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return copy(p, r.data[off:]), nil
+}
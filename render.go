@@ -0,0 +1,387 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"iter"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// ColorSpace selects the color space of rendered output.
+type ColorSpace byte
+
+// Possible values for ColorSpace.
+const (
+	// ColorSpaceRGB produces an *image.NRGBA when there is no background color, or an *image.RGBA when there is.
+	ColorSpaceRGB ColorSpace = iota
+	// ColorSpaceGray produces an *image.Gray.
+	ColorSpaceGray
+	// ColorSpaceCMYK produces an *image.CMYK.
+	ColorSpaceCMYK
+)
+
+// AntiAliasing selects how much anti-aliasing is used when rendering.
+type AntiAliasing byte
+
+// Possible values for AntiAliasing.
+const (
+	// AntiAliasDefault uses MuPDF's default, which is the same as AntiAliasHigh.
+	AntiAliasDefault AntiAliasing = iota
+	// AntiAliasNone turns anti-aliasing off.
+	AntiAliasNone
+	// AntiAliasLow uses 2 bits of anti-aliasing, for 4 levels of coverage.
+	AntiAliasLow
+	// AntiAliasMedium uses 4 bits of anti-aliasing, for 16 levels of coverage.
+	AntiAliasMedium
+	// AntiAliasHigh uses 8 bits of anti-aliasing, for 256 levels of coverage.
+	AntiAliasHigh
+)
+
+// RenderOptions controls how a page is rendered by RenderPageWithOptions, Page.RenderWithOptions, and RenderPages. The
+// zero value renders at 72 dpi into a transparent *image.NRGBA, as RenderPage does.
+type RenderOptions struct {
+	// Background is the color to fill the page with before drawing it, which makes the output opaque. If nil, RGB
+	// output is transparent where the page paints nothing, while grayscale and CMYK output, which have no alpha
+	// channel, are filled with white. Any alpha in the color is ignored.
+	Background color.Color
+	// Search controls how Needle is matched. May be nil for the defaults.
+	Search *SearchOptions
+	// Transform, if not nil, maps the page to the output in place of DPI, XDPI, YDPI, and Rotation, allowing any
+	// combination of scaling, rotation, flipping, and skewing. Its translation is ignored, as the output always starts
+	// at the top-left corner of the transformed page. Unlike DPI, its scale is not capped. A Transform that is not finite
+	// or that collapses the page results in ErrInvalidTransform.
+	Transform *Matrix
+	// Needle is the text to search for on each page. If not empty, the matches are returned in each RenderedPage, as
+	// with RenderPageWithSearch.
+	Needle string
+	// MinLineWidth is the minimum width, in pixels, of stroked lines, so that hairlines stay visible when zoomed out. A
+	// value of 0 or less leaves lines as the page draws them.
+	MinLineWidth float64
+	// Rotation is the number of degrees to rotate the page by, clockwise, after scaling it.
+	Rotation float64
+	// DPI is the resolution to render at. A value of 0 or less means 72.
+	DPI int
+	// XDPI, if greater than 0, is the horizontal resolution to render at in place of DPI, as measured before rotation.
+	XDPI int
+	// YDPI, if greater than 0, is the vertical resolution to render at in place of DPI, as measured before rotation.
+	YDPI int
+	// ColorSpace is the color space of the output.
+	ColorSpace ColorSpace
+	// TextAntiAliasing controls the anti-aliasing of text.
+	TextAntiAliasing AntiAliasing
+	// GraphicsAntiAliasing controls the anti-aliasing of everything other than text.
+	GraphicsAntiAliasing AntiAliasing
+	// Premultiplied, if true, returns transparent RGB output as an *image.RGBA, whose alpha is premultiplied, just as
+	// MuPDF renders it, rather than as an *image.NRGBA, saving a pass over the pixels to convert them.
+	Premultiplied bool
+}
+
+func (o *RenderOptions) dpi() int {
+	if o == nil || o.DPI <= 0 {
+		return 72
+	}
+	return o.DPI
+}
+
+// transform returns the Matrix that maps the page to the output, before it is fitted to the page.
+func (o *RenderOptions) transform() (Matrix, error) {
+	if o == nil {
+		return dpiToMatrix(72), nil
+	}
+	if o.Transform != nil {
+		if !o.Transform.valid() {
+			return Matrix{}, ErrInvalidTransform
+		}
+		return *o.Transform, nil
+	}
+	if math.IsNaN(o.Rotation) || math.IsInf(o.Rotation, 0) {
+		return Matrix{}, ErrInvalidTransform
+	}
+	xdpi := o.dpi()
+	if o.XDPI > 0 {
+		xdpi = o.XDPI
+	}
+	ydpi := o.dpi()
+	if o.YDPI > 0 {
+		ydpi = o.YDPI
+	}
+	m := ScaleMatrix(dpiToScale(xdpi), dpiToScale(ydpi))
+	if o.Rotation != 0 {
+		m = m.Concat(RotateMatrix(o.Rotation))
+	}
+	return m, nil
+}
+
+func (o *RenderOptions) search() (needle string, opts *SearchOptions) {
+	if o == nil {
+		return "", nil
+	}
+	return o.Needle, o.Search
+}
+
+func (o *RenderOptions) colorSpace() ColorSpace {
+	if o == nil {
+		return ColorSpaceRGB
+	}
+	return o.ColorSpace
+}
+
+// newImage returns an empty image of the kind opts asks for.
+func (o *RenderOptions) newImage(rect image.Rectangle) image.Image {
+	switch {
+	case o.colorSpace() == ColorSpaceGray:
+		return image.NewGray(rect)
+	case o.colorSpace() == ColorSpaceCMYK:
+		return image.NewCMYK(rect)
+	case o.transparent() && (o == nil || !o.Premultiplied):
+		return image.NewNRGBA(rect)
+	default:
+		return image.NewRGBA(rect)
+	}
+}
+
+// forImage returns a copy of opts with the color space, and whether the output is premultiplied, set to match dst.
+func (o *RenderOptions) forImage(dst image.Image) (*RenderOptions, error) {
+	if _, _, _, err := imageSamples(dst); err != nil {
+		return nil, err
+	}
+	var opts RenderOptions
+	if o != nil {
+		opts = *o
+	}
+	switch dst.(type) {
+	case *image.Gray:
+		opts.ColorSpace = ColorSpaceGray
+	case *image.CMYK:
+		opts.ColorSpace = ColorSpaceCMYK
+	case *image.RGBA:
+		opts.ColorSpace = ColorSpaceRGB
+		opts.Premultiplied = true
+	default:
+		opts.ColorSpace = ColorSpaceRGB
+		opts.Premultiplied = false
+	}
+	return &opts, nil
+}
+
+// transparent reports whether the output keeps an alpha channel.
+func (o *RenderOptions) transparent() bool {
+	return o.colorSpace() == ColorSpaceRGB && (o == nil || o.Background == nil)
+}
+
+// background returns the components of the background color in the output's color space, as MuPDF expects them.
+func (o *RenderOptions) background() [4]C.float {
+	var bg color.Color = color.White
+	if o != nil && o.Background != nil {
+		bg = o.Background
+	}
+	// The conversions to gray and CMYK go through RGBA(), which would premultiply any alpha, so make it opaque first.
+	rgb, _ := color.NRGBAModel.Convert(bg).(color.NRGBA)
+	rgb.A = 0xff
+	switch o.colorSpace() {
+	case ColorSpaceGray:
+		gray, _ := color.GrayModel.Convert(rgb).(color.Gray)
+		return [4]C.float{C.float(gray.Y) / 255}
+	case ColorSpaceCMYK:
+		cmyk, _ := color.CMYKModel.Convert(rgb).(color.CMYK)
+		return [4]C.float{C.float(cmyk.C) / 255, C.float(cmyk.M) / 255, C.float(cmyk.Y) / 255, C.float(cmyk.K) / 255}
+	default:
+		return [4]C.float{C.float(rgb.R) / 255, C.float(rgb.G) / 255, C.float(rgb.B) / 255}
+	}
+}
+
+// applyAntiAliasing sets the anti-aliasing and minimum line width on ctx, which must be a context used only for this
+// render.
+func (o *RenderOptions) applyAntiAliasing(ctx *C.fz_context) {
+	if o == nil {
+		return
+	}
+	if bits, ok := o.TextAntiAliasing.bits(); ok {
+		C.fz_set_text_aa_level(ctx, bits)
+	}
+	if bits, ok := o.GraphicsAntiAliasing.bits(); ok {
+		C.fz_set_graphics_aa_level(ctx, bits)
+	}
+	if o.MinLineWidth > 0 {
+		C.fz_set_graphics_min_line_width(ctx, C.float(o.MinLineWidth))
+	}
+}
+
+// bits returns the number of bits of anti-aliasing, or false for AntiAliasDefault.
+func (a AntiAliasing) bits() (C.int, bool) {
+	switch a {
+	case AntiAliasNone:
+		return 0, true
+	case AntiAliasLow:
+		return 2, true
+	case AntiAliasMedium:
+		return 4, true
+	case AntiAliasHigh:
+		return 8, true
+	default:
+		return 0, false
+	}
+}
+
+func (cs ColorSpace) fz(ctx *C.fz_context) *C.fz_colorspace {
+	switch cs {
+	case ColorSpaceGray:
+		return C.fz_device_gray(ctx)
+	case ColorSpaceCMYK:
+		return C.fz_device_cmyk(ctx)
+	default:
+		return C.fz_device_rgb(ctx)
+	}
+}
+
+// RenderPageWithOptions renders the specified page as described by opts, which may be nil for the defaults. If ctx is
+// cancelled, the render is abandoned and ctx.Err() is returned.
+func (d *Document) RenderPageWithOptions(ctx context.Context, pageNumber int, opts *RenderOptions) (*RenderedPage, error) {
+	needle, searchOpts := opts.search()
+	s, err := newSearcher(needle, searchOpts)
+	if err != nil {
+		return nil, err
+	}
+	m, err := opts.transform()
+	if err != nil {
+		return nil, err
+	}
+	return d.renderPage(ctx, pageNumber, s, searchOpts.maxHits(), func(*C.fz_page) (Matrix, error) { return m, nil }, opts)
+}
+
+// RenderPageInto renders the specified page as described by opts, which may be nil for the defaults, straight into the
+// pixels of dst, so that a buffer can be reused from one render to the next rather than a new image being allocated for
+// each. dst must be an *image.RGBA, which receives premultiplied alpha, an *image.NRGBA, an *image.Gray, or an
+// *image.CMYK, and its type takes the place of opts.ColorSpace and opts.Premultiplied. The page is drawn with its
+// top-left corner at dst.Bounds().Min and clipped to dst.Bounds(); the rest of dst is cleared to the background color,
+// or to transparent if there is none. The returned RenderedPage has dst as its Output, and its search hits and links
+// are relative to dst.Bounds().Min. If ctx is cancelled, the render is abandoned, leaving dst partially drawn, and
+// ctx.Err() is returned.
+func (d *Document) RenderPageInto(ctx context.Context, pageNumber int, dst image.Image, opts *RenderOptions) (*RenderedPage, error) {
+	opts, err := opts.forImage(dst)
+	if err != nil {
+		return nil, err
+	}
+	needle, searchOpts := opts.search()
+	s, err := newSearcher(needle, searchOpts)
+	if err != nil {
+		return nil, err
+	}
+	m, err := opts.transform()
+	if err != nil {
+		return nil, err
+	}
+	return d.runRender(ctx, opts, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		rendered, job, prepareErr := d.prepareRender(pageNumber, s, searchOpts.maxHits(), func(*C.fz_page) (Matrix, error) {
+			return m, nil
+		}, c)
+		if prepareErr != nil {
+			return nil, nil, prepareErr
+		}
+		renderInto(job, dst)
+		return rendered, job, nil
+	})
+}
+
+// renderInto points a job prepared for the whole page at dst, with the top-left corner of the page at the top-left
+// corner of dst.
+func renderInto(job *renderJob, dst image.Image) {
+	size := dst.Bounds().Size()
+	job.dst = dst
+	job.bbox.x1 = job.bbox.x0 + C.int(size.X)
+	job.bbox.y1 = job.bbox.y0 + C.int(size.Y)
+}
+
+type renderResult struct {
+	page *RenderedPage
+	err  error
+}
+
+// RenderPages renders the specified pages using up to workers goroutines at once, yielding each page as soon as it is
+// done, so they may arrive in a different order than requested; use RenderedPage.PageNumber to tell them apart. A
+// workers value of 0 or less means runtime.GOMAXPROCS(0). Each page is rendered as RenderPageWithSearch would, with only
+// the parts that must be serialized done while holding the document lock, so the rasterization of the pages proceeds in
+// parallel. If a page fails to render, a RenderedPage holding just its PageNumber is yielded along with the error and
+// the remaining pages are still rendered. If ctx is cancelled, the pages in progress are abandoned, no further pages are
+// started, and ctx.Err() is yielded. Stopping the iteration early also abandons the pages in progress.
+func (d *Document) RenderPages(ctx context.Context, pages []int, opts *RenderOptions, workers int) iter.Seq2[*RenderedPage, error] {
+	return func(yield func(*RenderedPage, error) bool) {
+		if len(pages) == 0 {
+			return
+		}
+		needle, searchOpts := opts.search()
+		s, err := newSearcher(needle, searchOpts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		m, err := opts.transform()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		transformFor := func(*C.fz_page) (Matrix, error) { return m, nil }
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		workers = min(workers, len(pages))
+		workCtx, cancel := context.WithCancel(ctx)
+		jobs := make(chan int)
+		results := make(chan renderResult)
+		go func() {
+			defer close(jobs)
+			for _, pageNumber := range pages {
+				select {
+				case jobs <- pageNumber:
+				case <-workCtx.Done():
+					return
+				}
+			}
+		}()
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Go(func() {
+				for pageNumber := range jobs {
+					page, pageErr := d.renderPage(workCtx, pageNumber, s, searchOpts.maxHits(), transformFor, opts)
+					if workCtx.Err() != nil {
+						// Pages cut short by cancellation are not reported; ctx.Err() is yielded at the end instead.
+						continue
+					}
+					if pageErr != nil {
+						page = &RenderedPage{PageNumber: pageNumber}
+					}
+					select {
+					case results <- renderResult{page: page, err: pageErr}:
+					case <-workCtx.Done():
+					}
+				}
+			})
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		defer func() {
+			// Stop handing out pages and drain what is already in flight, so none of the goroutines are left blocked.
+			cancel()
+			for range results {
+			}
+		}()
+		for result := range results {
+			if !yield(result.page, result.err) {
+				return
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
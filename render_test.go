@@ -0,0 +1,283 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestRenderPages(t *testing.T) {
+	doc := loadTestDocument(t)
+	count := doc.PageCount()
+	pages := make([]int, count)
+	for i := range pages {
+		pages[i] = count - 1 - i
+	}
+	opts := &pdf.RenderOptions{DPI: 50, Needle: "GURPS"}
+	seen := make(map[int]*pdf.RenderedPage)
+	for page, err := range doc.RenderPages(context.Background(), pages, opts, 4) {
+		if err != nil {
+			t.Fatalf("page %d: %v", page.PageNumber, err)
+		}
+		if seen[page.PageNumber] != nil {
+			t.Fatalf("page %d was yielded more than once", page.PageNumber)
+		}
+		seen[page.PageNumber] = page
+	}
+	if len(seen) != count {
+		t.Fatalf("expected %d pages, got %d", count, len(seen))
+	}
+
+	// Rendering in parallel must produce exactly what rendering one page at a time does.
+	for _, pageNumber := range []int{0, count / 2, count - 1} {
+		want, err := doc.RenderPageWithSearch(context.Background(), pageNumber, 50, "GURPS", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := seen[pageNumber]
+		if got.Image.Rect != want.Image.Rect || !bytes.Equal(got.Image.Pix, want.Image.Pix) {
+			t.Errorf("page %d: image differs from RenderPageWithSearch", pageNumber)
+		}
+		if len(got.SearchHits) != len(want.SearchHits) {
+			t.Errorf("page %d: expected %d search hits, got %d", pageNumber, len(want.SearchHits), len(got.SearchHits))
+		}
+		if len(got.Links) != len(want.Links) {
+			t.Errorf("page %d: expected %d links, got %d", pageNumber, len(want.Links), len(got.Links))
+		}
+	}
+}
+
+func TestRenderPagesErrors(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	// A bad page is reported without stopping the others.
+	var good, bad int
+	for page, err := range doc.RenderPages(context.Background(), []int{0, -1, 1}, nil, 0) {
+		switch {
+		case errors.Is(err, pdf.ErrInvalidPageNumber):
+			if page == nil || page.PageNumber != -1 {
+				t.Errorf("expected the failed page to carry its page number, got %v", page)
+			}
+			bad++
+		case err != nil:
+			t.Fatal(err)
+		default:
+			good++
+		}
+	}
+	if good != 2 || bad != 1 {
+		t.Errorf("expected 2 rendered pages and 1 failure, got %d and %d", good, bad)
+	}
+
+	invalid := &pdf.RenderOptions{Needle: "(", Search: &pdf.SearchOptions{Regexp: true}}
+	for _, err := range doc.RenderPages(context.Background(), []int{0}, invalid, 1) {
+		if err == nil {
+			t.Error("expected an error for an invalid pattern")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var last error
+	for _, err := range doc.RenderPages(ctx, []int{0, 1, 2}, nil, 2) {
+		last = err
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", last)
+	}
+
+	// Stopping early must not leave anything running that would block Release.
+	for range doc.RenderPages(context.Background(), []int{0, 1, 2, 3}, nil, 2) {
+		break
+	}
+	doc.Release()
+	for _, err := range doc.RenderPages(context.Background(), []int{0}, nil, 1) {
+		if !errors.Is(err, pdf.ErrDocumentReleased) {
+			t.Errorf("expected ErrDocumentReleased, got %v", err)
+		}
+	}
+}
+
+func TestRenderPageWithOptions(t *testing.T) {
+	doc := loadTestDocument(t)
+	plain, err := doc.RenderPageWithOptions(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Image == nil || plain.Output != plain.Image {
+		t.Fatal("expected the default output to be the transparent *image.NRGBA")
+	}
+
+	gray, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{ColorSpace: pdf.ColorSpaceGray})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, ok := gray.Output.(*image.Gray); !ok {
+		t.Errorf("expected *image.Gray, got %T", gray.Output)
+	} else if img.Rect != plain.Image.Rect {
+		t.Errorf("expected bounds %v, got %v", plain.Image.Rect, img.Rect)
+	}
+	if gray.Image != nil {
+		t.Error("expected Image to be nil for grayscale output")
+	}
+
+	cmyk, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{ColorSpace: pdf.ColorSpaceCMYK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cmyk.Output.(*image.CMYK); !ok {
+		t.Errorf("expected *image.CMYK, got %T", cmyk.Output)
+	}
+
+	background := color.RGBA{R: 255, A: 255}
+	opaque, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{
+		Background:           background,
+		DPI:                  100,
+		TextAntiAliasing:     pdf.AntiAliasNone,
+		GraphicsAntiAliasing: pdf.AntiAliasLow,
+		MinLineWidth:         1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, ok := opaque.Output.(*image.RGBA)
+	if !ok {
+		t.Fatalf("expected *image.RGBA, got %T", opaque.Output)
+	}
+	if img.Rect.Dx() <= plain.Image.Rect.Dx() {
+		t.Errorf("expected a larger image at 100 dpi than at 72 dpi, got %v", img.Rect)
+	}
+	// The corner of the page is empty, so it shows the background.
+	if got := img.RGBAAt(img.Rect.Min.X, img.Rect.Min.Y); got != background {
+		t.Errorf("expected the background color %v in the corner, got %v", background, got)
+	}
+
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	fromPage, err := page.RenderWithOptions(context.Background(), &pdf.RenderOptions{ColorSpace: pdf.ColorSpaceGray})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pageGray, isGray := fromPage.Output.(*image.Gray); !isGray || !bytes.Equal(pageGray.Pix, gray.Output.(*image.Gray).Pix) {
+		t.Error("expected Page.RenderWithOptions to match RenderPageWithOptions")
+	}
+}
+
+func TestRenderTranslucentBackground(t *testing.T) {
+	doc, err := pdf.New(textPDF(""), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	// The alpha of the background is ignored, so a half-transparent white must still render as white.
+	background := color.NRGBA{R: 255, G: 255, B: 255, A: 128}
+	gray, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{
+		Background: background,
+		ColorSpace: pdf.ColorSpaceGray,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, ok := gray.Output.(*image.Gray); !ok {
+		t.Errorf("expected *image.Gray, got %T", gray.Output)
+	} else if got := img.GrayAt(img.Rect.Min.X, img.Rect.Min.Y); got.Y != 255 {
+		t.Errorf("expected a white gray background, got %v", got)
+	}
+	cmyk, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{
+		Background: background,
+		ColorSpace: pdf.ColorSpaceCMYK,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, ok := cmyk.Output.(*image.CMYK); !ok {
+		t.Errorf("expected *image.CMYK, got %T", cmyk.Output)
+	} else if got := img.CMYKAt(img.Rect.Min.X, img.Rect.Min.Y); got != (color.CMYK{}) {
+		t.Errorf("expected a white CMYK background, got %v", got)
+	}
+}
+
+func TestRenderPageInto(t *testing.T) {
+	doc := loadTestDocument(t)
+	want, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{DPI: 100, Needle: "GURPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	premultiplied, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{DPI: 100, Premultiplied: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba, ok := premultiplied.Output.(*image.RGBA)
+	if !ok {
+		t.Fatalf("expected *image.RGBA, got %T", premultiplied.Output)
+	}
+	if premultiplied.Image != nil {
+		t.Error("expected Image to be nil for premultiplied output")
+	}
+	if rgba.Rect != want.Image.Rect {
+		t.Errorf("expected bounds %v, got %v", want.Image.Rect, rgba.Rect)
+	}
+
+	// Rendering into a reused buffer must produce the same pixels as a fresh render, wherever the buffer starts.
+	bounds := want.Image.Rect.Add(image.Pt(10, 20))
+	buffer := image.NewNRGBA(bounds)
+	for range 2 {
+		rendered, renderErr := doc.RenderPageInto(context.Background(), 0, buffer, &pdf.RenderOptions{DPI: 100, Needle: "GURPS"})
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		if rendered.Output != buffer || rendered.Image != buffer {
+			t.Fatal("expected the buffer to be returned as the output")
+		}
+		if !bytes.Equal(buffer.Pix, want.Image.Pix) {
+			t.Error("expected the same pixels as RenderPageWithOptions")
+		}
+		if len(rendered.SearchHits) != len(want.SearchHits) {
+			t.Errorf("expected %d search hits, got %d", len(want.SearchHits), len(rendered.SearchHits))
+		}
+	}
+
+	// A smaller buffer gets the top-left corner of the page.
+	corner := image.NewRGBA(image.Rect(0, 0, 50, 40))
+	if _, err = doc.RenderPageInto(context.Background(), 0, corner, &pdf.RenderOptions{DPI: 100, Premultiplied: true}); err != nil {
+		t.Fatal(err)
+	}
+	for y := range 40 {
+		if !bytes.Equal(corner.Pix[y*corner.Stride:(y+1)*corner.Stride], rgba.Pix[y*rgba.Stride:y*rgba.Stride+50*4]) {
+			t.Errorf("row %d of the smaller buffer differs from the whole page", y)
+			break
+		}
+	}
+
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	gray := image.NewGray(want.Image.Rect)
+	if _, err = page.RenderInto(context.Background(), gray, &pdf.RenderOptions{DPI: 100}); err != nil {
+		t.Fatal(err)
+	}
+	wantGray, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{DPI: 100, ColorSpace: pdf.ColorSpaceGray})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gray.Pix, wantGray.Output.(*image.Gray).Pix) {
+		t.Error("expected Page.RenderInto to match RenderPageWithOptions")
+	}
+
+	if _, err = doc.RenderPageInto(context.Background(), 0, image.NewAlpha(bounds), nil); !errors.Is(err, pdf.ErrUnsupportedImage) {
+		t.Errorf("expected ErrUnsupportedImage, got %v", err)
+	}
+	if _, err = doc.RenderPageInto(context.Background(), 0, image.NewRGBA(image.Rectangle{}), nil); !errors.Is(err, pdf.ErrUnsupportedImage) {
+		t.Errorf("expected ErrUnsupportedImage for an empty image, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+// Writes the whole of doc, including any changes made to it, to a new buffer. Returns NULL if doc is not a PDF or it
+// threw. The caller must drop the returned buffer.
+fz_buffer *wrapped_write_pdf(fz_context *ctx, fz_document *doc) {
+	fz_buffer *buf = NULL;
+	fz_output *out = NULL;
+	pdf_document *pdf = pdf_specifics(ctx, doc);
+	if (pdf == NULL) {
+		return NULL;
+	}
+	fz_var(buf);
+	fz_var(out);
+	fz_try(ctx) {
+		buf = fz_new_buffer(ctx, 8192);
+		out = fz_new_output_with_buffer(ctx, buf);
+		pdf_write_document(ctx, pdf, out, &pdf_default_write_options);
+		fz_close_output(ctx, out);
+	}
+	fz_always(ctx) {
+		fz_drop_output(ctx, out);
+	}
+	fz_catch(ctx) {
+		fz_drop_buffer(ctx, buf);
+		buf = NULL;
+	}
+	return buf;
+}
+*/
+import "C"
+
+import (
+	"io"
+	"unsafe"
+)
+
+// Save writes the document to w as a complete PDF, including the changes made to it, such as annotations that have
+// been created, updated, or deleted. The document itself is left as it is, so it can go on being used and saved
+// again. Only PDF documents can be saved; for others, ErrNotPDFData is returned.
+func (d *Document) Save(w io.Writer) error {
+	data, err := d.save()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// save returns the bytes of the document as Save writes them, copied out of MuPDF so that Save does not hold d.lock
+// while writing them.
+func (d *Document) save() ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	if C.pdf_specifics(d.ctx, d.doc) == nil {
+		return nil, ErrNotPDFData
+	}
+	buf := C.wrapped_write_pdf(d.ctx, d.doc)
+	if buf == nil {
+		return nil, ErrUnableToSave
+	}
+	defer C.fz_drop_buffer(d.ctx, buf)
+	var data *C.uchar
+	size := C.fz_buffer_storage(d.ctx, buf, &data)
+	if data == nil || size == 0 {
+		return nil, ErrUnableToSave
+	}
+	return append([]byte(nil), unsafe.Slice((*byte)(unsafe.Pointer(data)), int(size))...), nil
+}
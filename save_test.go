@@ -0,0 +1,38 @@
+package pdf_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestSave(t *testing.T) {
+	doc := loadTestDocument(t)
+	var buffer bytes.Buffer
+	if err := doc.Save(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := pdf.New(buffer.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saved.Release()
+	if saved.PageCount() != doc.PageCount() {
+		t.Errorf("expected %d pages once saved, got %d", doc.PageCount(), saved.PageCount())
+	}
+
+	const svg = `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`
+	svgDoc, err := pdf.NewWithMIME([]byte(svg), "image/svg+xml", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svgDoc.Save(&buffer); !errors.Is(err, pdf.ErrNotPDFData) {
+		t.Errorf("expected ErrNotPDFData, got %v", err)
+	}
+	svgDoc.Release()
+	if err = svgDoc.Save(&buffer); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased, got %v", err)
+	}
+}
@@ -0,0 +1,552 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+#include <mupdf/ucdn.h>
+
+// Returns the base character of c with any diacritics removed, by repeatedly applying its canonical decomposition for
+// as long as that splits off a combining mark.
+int remove_diacritics(int c) {
+	uint32_t a, b;
+	while (c > 0 && ucdn_decompose((uint32_t)c, &a, &b) && (b == 0 || ucdn_get_combining_class(b) != 0)) {
+		c = (int)a;
+	}
+	return c;
+}
+
+// Defined in text.go.
+fz_stext_line *stext_block_first_line(fz_stext_block *block);
+fz_stext_block *stext_block_first_child(fz_stext_block *block);
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"iter"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchOptions controls a search. A nil *SearchOptions is equivalent to the zero value, which matches the way MuPDF
+// searches by default: case-insensitively, with any run of whitespace in the needle matching any run of whitespace in
+// the text.
+type SearchOptions struct {
+	// MaxHits is the maximum number of hit quads returned; a match that wraps across lines uses one quad per line. A
+	// value of 0 or less, or one larger than OverallMaxHits, means OverallMaxHits.
+	MaxHits int
+	// ExactCase requires the case of the text to match the case of the needle.
+	ExactCase bool
+	// WholeWord only matches text that is not immediately preceded or followed by a letter, digit, or underscore.
+	WholeWord bool
+	// Regexp treats the needle as a pattern in the syntax of Go's regexp package, matched against the text of each
+	// page. Lines are joined with a single space.
+	Regexp bool
+	// IgnoreDiacritics matches text regardless of accents and other diacritical marks, so "resume" matches "résumé".
+	IgnoreDiacritics bool
+}
+
+// PageHits holds the search hits found on a single page.
+type PageHits struct {
+	Hits       []*SearchHit
+	PageNumber int
+}
+
+// SearchHit holds a single match of a search.
+type SearchHit struct {
+	// Quads holds the area covered by the match, in rendered-image pixel space. A match that wraps across lines has one
+	// quad per line. Unlike the rectangles in RenderedPage.SearchHits, the quads follow the text exactly, even when it
+	// is rotated.
+	Quads []Quad
+	// Match is the zero-based position of the match within the results of a search, counting across pages for
+	// Document.Search.
+	Match int
+}
+
+// Bounds returns the smallest image.Rectangle that encloses all of the hit's quads.
+func (h *SearchHit) Bounds() image.Rectangle {
+	var r image.Rectangle
+	for i, q := range h.Quads {
+		if i == 0 {
+			r = q.Bounds()
+		} else {
+			r = r.Union(q.Bounds())
+		}
+	}
+	return r
+}
+
+// SearchResult holds a single match of a search along with the text surrounding it, for listing the results of a
+// search.
+type SearchResult struct {
+	// Before holds the text immediately preceding the match on the page.
+	Before string
+	// Match holds the text that was matched, as it appears on the page.
+	Match string
+	// After holds the text immediately following the match on the page. Before, Match, and After are all empty if the
+	// characters of the match could not be found in the text of the page.
+	After string
+	// Quads holds the area covered by the match, in rendered-image pixel space. A match that wraps across lines has one
+	// quad per line.
+	Quads      []Quad
+	PageNumber int
+}
+
+// Bounds returns the smallest image.Rectangle that encloses all of the result's quads.
+func (r *SearchResult) Bounds() image.Rectangle {
+	hit := SearchHit{Quads: r.Quads}
+	return hit.Bounds()
+}
+
+// quadCount returns the total number of quads in the hits.
+func (h *PageHits) quadCount() int {
+	count := 0
+	for _, hit := range h.Hits {
+		count += len(hit.Quads)
+	}
+	return count
+}
+
+func (o *SearchOptions) maxHits() int {
+	if o == nil || o.MaxHits <= 0 {
+		return OverallMaxHits
+	}
+	return min(o.MaxHits, OverallMaxHits)
+}
+
+// searcher holds a needle prepared for searching. When re is nil, the search is handed to MuPDF, which is only done for
+// options that match MuPDF's own behavior; otherwise, the page's structured text is matched against re in Go.
+type searcher struct {
+	re               *regexp.Regexp
+	needle           string
+	wholeWord        bool
+	ignoreDiacritics bool
+}
+
+func newSearcher(needle string, opts *SearchOptions) (*searcher, error) {
+	if opts == nil || (!opts.ExactCase && !opts.WholeWord && !opts.Regexp && !opts.IgnoreDiacritics) {
+		return &searcher{needle: needle}, nil
+	}
+	s := &searcher{needle: needle}
+	s.wholeWord = opts.WholeWord
+	s.ignoreDiacritics = opts.IgnoreDiacritics
+	if s.ignoreDiacritics {
+		needle = removeDiacritics(needle)
+	}
+	var pattern string
+	if opts.Regexp {
+		pattern = needle
+	} else {
+		// Mimic MuPDF's loose matching of whitespace.
+		fields := strings.Fields(needle)
+		for i, field := range fields {
+			fields[i] = regexp.QuoteMeta(field)
+		}
+		pattern = strings.Join(fields, `\s+`)
+	}
+	if !opts.ExactCase {
+		pattern = "(?i)" + pattern
+	}
+	var err error
+	if s.re, err = regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSearchPattern, err)
+	}
+	return s, nil
+}
+
+// removeDiacritics returns str with the diacritical marks removed from each character, dropping any standalone
+// combining marks.
+func removeDiacritics(str string) string {
+	var buffer strings.Builder
+	for _, ch := range str {
+		if ch = foldDiacritics(ch); ch >= 0 {
+			buffer.WriteRune(ch)
+		}
+	}
+	return buffer.String()
+}
+
+// foldDiacritics returns the base character of ch with any diacritical marks removed, or -1 if ch is itself a
+// combining mark.
+func foldDiacritics(ch rune) rune {
+	// Nothing below U+00C0 decomposes.
+	if ch < 0xC0 {
+		return ch
+	}
+	if unicode.Is(unicode.Mn, ch) {
+		return -1
+	}
+	return rune(C.remove_diacritics(C.int(ch)))
+}
+
+// Search looks for needle on every page of the document, in page order, without rendering any of them. Each page with
+// at least one hit is yielded along with its hits, in the pixel space of the page rendered at the requested dpi. The
+// search stops once the maximum number of hits has been returned. Pages without hits are not yielded. If an error
+// occurs, including the context being cancelled or opts holding an invalid regular expression, it is yielded with a nil
+// *PageHits and the search stops.
+//
+// Calls into the underlying MuPDF library are made one page at a time, so other calls on the document may run between
+// pages.
+func (d *Document) Search(ctx context.Context, needle string, dpi int, opts *SearchOptions) iter.Seq2[*PageHits, error] {
+	return func(yield func(*PageHits, error) bool) {
+		if needle == "" {
+			return
+		}
+		s, err := newSearcher(needle, opts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		c := newCookie(ctx)
+		defer c.release()
+		scale := dpiToScale(dpi)
+		remaining := opts.maxHits()
+		matchCount := 0
+		for pageNumber := 0; remaining > 0; pageNumber++ {
+			if err = ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			var hits *PageHits
+			var done bool
+			hits, done, err = d.searchPage(pageNumber, s, scale, remaining, matchCount, c)
+			if err = c.check(err); err != nil {
+				yield(nil, err)
+				return
+			}
+			if done {
+				return
+			}
+			if len(hits.Hits) != 0 {
+				remaining -= hits.quadCount()
+				matchCount += len(hits.Hits)
+				if !yield(hits, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// SearchResults looks for needle on every page of the document, in page order, without rendering any of them, and
+// yields each match along with up to contextChars characters of the text on either side of it. Lines of text are
+// joined by a single space. Matching is the same as for Search, including handing the search to MuPDF for the default
+// options, as is the handling of the maximum number of hits and of errors.
+func (d *Document) SearchResults(ctx context.Context, needle string, dpi, contextChars int, opts *SearchOptions) iter.Seq2[*SearchResult, error] {
+	return func(yield func(*SearchResult, error) bool) {
+		if needle == "" {
+			return
+		}
+		s, err := newSearcher(needle, opts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		c := newCookie(ctx)
+		defer c.release()
+		scale := dpiToScale(dpi)
+		contextChars = max(contextChars, 0)
+		remaining := opts.maxHits()
+		for pageNumber := 0; remaining > 0; pageNumber++ {
+			if err = ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			var results []*SearchResult
+			var done bool
+			results, done, err = d.searchPageResults(pageNumber, s, scale, remaining, contextChars, c)
+			if err = c.check(err); err != nil {
+				yield(nil, err)
+				return
+			}
+			if done {
+				return
+			}
+			for _, result := range results {
+				remaining -= len(result.Quads)
+				if !yield(result, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// searchPageResults returns the matches on the page, along with their surrounding text, up to a total of maxHits quads,
+// or done set to true if the page number is past the end of the document.
+func (d *Document) searchPageResults(pageNumber int, s *searcher, scale float64, maxHits, contextChars int, c *cookie) (results []*SearchResult, done bool, err error) {
+	done, err = d.withDisplayList(pageNumber, c, func(displayList *C.fz_display_list) error {
+		stext := d.newStructuredText(displayList, 0, c)
+		if stext == nil {
+			return ErrUnableToExtractText
+		}
+		defer C.fz_drop_stext_page(d.ctx, stext)
+		t := newSearchText(stext, s.ignoreDiacritics)
+		var matches []textMatch
+		if s.re == nil {
+			matches = t.locate(d.searchDisplayListQuads(displayList, s.needle, maxHits))
+		} else {
+			matches = limitMatches(s.match(t), min(maxHits, OverallMaxHits))
+		}
+		results = make([]*SearchResult, len(matches))
+		for i, match := range matches {
+			result := &SearchResult{
+				Quads:      make([]Quad, len(match.quads)),
+				PageNumber: pageNumber,
+			}
+			result.Before, result.Match, result.After = t.context(match, contextChars)
+			for j, q := range match.quads {
+				result.Quads[j] = scaleQuad(q, scale)
+			}
+			results[i] = result
+		}
+		return nil
+	})
+	return results, done, err
+}
+
+// searchPage returns up to maxHits hit quads on the page, numbering the matches from firstMatch, or done set to true if
+// the page number is past the end of the document.
+func (d *Document) searchPage(pageNumber int, s *searcher, scale float64, maxHits, firstMatch int, c *cookie) (hits *PageHits, done bool, err error) {
+	done, err = d.withDisplayList(pageNumber, c, func(displayList *C.fz_display_list) error {
+		matches, searchErr := d.search(displayList, s, maxHits, c)
+		if searchErr != nil {
+			return searchErr
+		}
+		hits = &PageHits{
+			Hits:       transformMatches(matches, ScaleMatrix(scale, scale), firstMatch),
+			PageNumber: pageNumber,
+		}
+		return nil
+	})
+	return hits, done, err
+}
+
+// withDisplayList calls fn with the display list of the page while holding d.lock, or returns done set to true if the
+// page number is past the end of the document.
+func (d *Document) withDisplayList(pageNumber int, c *cookie, fn func(displayList *C.fz_display_list) error) (done bool, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return false, ErrDocumentReleased
+	}
+	if pageNumber >= d.pageCount() {
+		return true, nil
+	}
+	page, err := d.loadPage(pageNumber)
+	if err != nil {
+		return false, err
+	}
+	defer C.fz_drop_page(d.ctx, page)
+	displayList := d.newDisplayList(page, c)
+	if displayList == nil {
+		return false, ErrUnableToExtractText
+	}
+	defer C.fz_drop_display_list(d.ctx, displayList)
+	return false, fn(displayList)
+}
+
+// search returns up to maxHits (capped by OverallMaxHits) unscaled quads of the matches in the display list, grouped by
+// match. The caller must hold d.lock.
+func (d *Document) search(displayList *C.fz_display_list, s *searcher, maxHits int, c *cookie) ([][]C.fz_quad, error) {
+	if s.re == nil {
+		return d.searchDisplayListQuads(displayList, s.needle, maxHits), nil
+	}
+	maxHits = min(maxHits, OverallMaxHits)
+	if s.needle == "" || maxHits <= 0 {
+		return nil, nil
+	}
+	stext := d.newStructuredText(displayList, 0, c)
+	if stext == nil {
+		return nil, ErrUnableToExtractText
+	}
+	defer C.fz_drop_stext_page(d.ctx, stext)
+	found := limitMatches(s.match(newSearchText(stext, s.ignoreDiacritics)), maxHits)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	matches := make([][]C.fz_quad, len(found))
+	for i, one := range found {
+		matches[i] = one.quads
+	}
+	return matches, nil
+}
+
+// limitMatches returns the leading matches whose quads total no more than maxHits.
+func limitMatches(matches []textMatch, maxHits int) []textMatch {
+	count := 0
+	for i, match := range matches {
+		if count += len(match.quads); count > maxHits {
+			return matches[:i]
+		}
+	}
+	return matches
+}
+
+// searchChar is a single character of the text being searched.
+type searchChar struct {
+	quad C.fz_quad
+	// offset is the byte offset of the character's folded form within searchText.text.
+	offset int
+	// line identifies the line the character is on; characters with the same line can share a quad.
+	line int
+	ch   rune
+	// synthetic is true for the spaces inserted between lines, which have no quad.
+	synthetic bool
+}
+
+// searchText is the text of a page prepared for matching, along with the position of each of its characters.
+type searchText struct {
+	text  string
+	chars []searchChar
+}
+
+func newSearchText(stext *C.fz_stext_page, ignoreDiacritics bool) *searchText {
+	var buffer strings.Builder
+	t := &searchText{}
+	line := 0
+	var walk func(block *C.fz_stext_block)
+	walk = func(block *C.fz_stext_block) {
+		for ; block != nil; block = block.next {
+			switch block._type {
+			case C.FZ_STEXT_BLOCK_TEXT:
+				for l := C.stext_block_first_line(block); l != nil; l = l.next {
+					if line != 0 {
+						t.chars = append(t.chars, searchChar{offset: buffer.Len(), line: line, ch: ' ', synthetic: true})
+						buffer.WriteByte(' ')
+					}
+					line++
+					for ch := l.first_char; ch != nil; ch = ch.next {
+						r := rune(ch.c)
+						t.chars = append(t.chars, searchChar{quad: ch.quad, offset: buffer.Len(), line: line, ch: r})
+						if ignoreDiacritics {
+							r = foldDiacritics(r)
+						}
+						if r >= 0 {
+							buffer.WriteRune(r)
+						}
+					}
+				}
+			case C.FZ_STEXT_BLOCK_STRUCT:
+				walk(C.stext_block_first_child(block))
+			default:
+			}
+		}
+	}
+	walk(stext.first_block)
+	t.text = buffer.String()
+	return t
+}
+
+// textMatch is a single match found in a searchText.
+type textMatch struct {
+	quads []C.fz_quad
+	// first and last delimit the matched characters within searchText.chars, as the half-open range [first, last).
+	// first is -1 if the characters could not be found.
+	first int
+	last  int
+}
+
+// match returns each match found in the text. Characters on the same line within a match share a single quad.
+func (s *searcher) match(t *searchText) []textMatch {
+	var matches []textMatch
+	for _, loc := range s.re.FindAllStringIndex(t.text, -1) {
+		if loc[0] == loc[1] || (s.wholeWord && !isWordBoundary(t.text, loc[0], loc[1])) {
+			continue
+		}
+		m := textMatch{first: sort.Search(len(t.chars), func(i int) bool { return t.chars[i].offset >= loc[0] })}
+		line := -1
+		for m.last = m.first; m.last < len(t.chars) && t.chars[m.last].offset < loc[1]; m.last++ {
+			c := &t.chars[m.last]
+			switch {
+			case c.synthetic:
+			case c.line == line:
+				m.quads[len(m.quads)-1].ur = c.quad.ur
+				m.quads[len(m.quads)-1].lr = c.quad.lr
+			default:
+				m.quads = append(m.quads, c.quad)
+				line = c.line
+			}
+		}
+		// Combining marks dropped by diacritic folding share the offset of the character that follows them, so pick up
+		// any that trail the match.
+		for m.last < len(t.chars) && !t.chars[m.last].synthetic && unicode.Is(unicode.Mn, t.chars[m.last].ch) {
+			m.last++
+		}
+		if len(m.quads) != 0 {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// locate returns a textMatch for each of the matches MuPDF found, given by their quads, delimiting the characters
+// whose centers lie within them. A match whose characters cannot be found has a first of -1.
+func (t *searchText) locate(matches [][]C.fz_quad) []textMatch {
+	located := make([]textMatch, len(matches))
+	for i, quads := range matches {
+		m := textMatch{quads: quads, first: -1, last: -1}
+		for j := range t.chars {
+			c := &t.chars[j]
+			if c.synthetic || !quadsContain(quads, (c.quad.ul.x+c.quad.lr.x)/2, (c.quad.ul.y+c.quad.lr.y)/2) {
+				continue
+			}
+			if m.first < 0 {
+				m.first = j
+			}
+			m.last = j + 1
+		}
+		located[i] = m
+	}
+	return located
+}
+
+// context returns the text of the match along with up to contextChars characters on either side of it. All three are
+// empty for a match whose characters could not be found, rather than being taken from an arbitrary spot on the page.
+func (t *searchText) context(m textMatch, contextChars int) (before, match, after string) {
+	if m.first < 0 {
+		return "", "", ""
+	}
+	return t.runes(m.first-contextChars, m.first), t.runes(m.first, m.last), t.runes(m.last, m.last+contextChars)
+}
+
+// quadsContain returns true if the point lies within the bounds of any of the quads.
+func quadsContain(quads []C.fz_quad, x, y C.float) bool {
+	for _, q := range quads {
+		if x >= min(q.ul.x, q.ll.x, q.ur.x, q.lr.x) && x <= max(q.ul.x, q.ll.x, q.ur.x, q.lr.x) &&
+			y >= min(q.ul.y, q.ll.y, q.ur.y, q.lr.y) && y <= max(q.ul.y, q.ll.y, q.ur.y, q.lr.y) {
+			return true
+		}
+	}
+	return false
+}
+
+// runes returns the original text of the characters in the range [first, last), clamped to the available characters.
+func (t *searchText) runes(first, last int) string {
+	first = max(first, 0)
+	last = min(last, len(t.chars))
+	var buffer strings.Builder
+	for i := first; i < last; i++ {
+		buffer.WriteRune(t.chars[i].ch)
+	}
+	return filterUnsafeRunes(buffer.String(), nil)
+}
+
+// isWordBoundary returns true if the text between start and end is neither preceded nor followed by a word character.
+func isWordBoundary(text string, start, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) || unicode.Is(unicode.Mn, ch)
+}
@@ -0,0 +1,330 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func loadTestDocument(t *testing.T) *pdf.Document {
+	t.Helper()
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := pdf.New(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(doc.Release)
+	return doc
+}
+
+func TestSearch(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	// The hits for page 0 must line up with those reported by RenderPage at the same dpi.
+	page, err := doc.RenderPage(0, 100, 1000, "GURPS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []*pdf.PageHits
+	total := 0
+	for hits, searchErr := range doc.Search(context.Background(), "GURPS", 100, nil) {
+		if searchErr != nil {
+			t.Fatal(searchErr)
+		}
+		if len(hits.Hits) == 0 {
+			t.Errorf("page %d was yielded without any hits", hits.PageNumber)
+		}
+		total += len(quadsOf(hits.Hits))
+		results = append(results, hits)
+	}
+	if len(results) == 0 || results[0].PageNumber != 0 {
+		t.Fatal("expected hits on page 0")
+	}
+	quads := quadsOf(results[0].Hits)
+	if len(quads) != len(page.SearchHits) {
+		t.Fatalf("expected %d hits on page 0, got %d", len(page.SearchHits), len(quads))
+	}
+	for i, q := range quads {
+		if q.Bounds() != page.SearchHits[i] {
+			t.Errorf("hit %d: expected bounds %v, got %v", i, page.SearchHits[i], q.Bounds())
+		}
+	}
+	match := 0
+	for i, one := range results {
+		if i > 0 && one.PageNumber <= results[i-1].PageNumber {
+			t.Errorf("pages were not yielded in order: %d followed %d", one.PageNumber, results[i-1].PageNumber)
+		}
+		for _, hit := range one.Hits {
+			if hit.Match != match {
+				t.Errorf("expected match number %d, got %d", match, hit.Match)
+			}
+			match++
+		}
+	}
+
+	// The total number of hits is capped by MaxHits, across pages.
+	limited := 0
+	for hits, searchErr := range doc.Search(context.Background(), "GURPS", 100, &pdf.SearchOptions{MaxHits: 3}) {
+		if searchErr != nil {
+			t.Fatal(searchErr)
+		}
+		limited += len(quadsOf(hits.Hits))
+	}
+	if limited != min(3, total) {
+		t.Errorf("expected %d hits with MaxHits of 3, got %d", min(3, total), limited)
+	}
+
+	// ... and by OverallMaxHits.
+	defer func(prev int) { pdf.OverallMaxHits = prev }(pdf.OverallMaxHits)
+	pdf.OverallMaxHits = 2
+	limited = 0
+	for hits, searchErr := range doc.Search(context.Background(), "GURPS", 100, &pdf.SearchOptions{MaxHits: 3}) {
+		if searchErr != nil {
+			t.Fatal(searchErr)
+		}
+		limited += len(quadsOf(hits.Hits))
+	}
+	if limited != min(2, total) {
+		t.Errorf("expected %d hits with OverallMaxHits of 2, got %d", min(2, total), limited)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var err error
+	for _, err = range doc.Search(ctx, "GURPS", 100, nil) {
+		break
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from Search with a cancelled context, got %v", err)
+	}
+
+	doc.Release()
+	err = nil
+	for _, err = range doc.Search(context.Background(), "GURPS", 100, nil) {
+		break
+	}
+	if !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from Search after release, got %v", err)
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	// \351 is é in WinAnsiEncoding.
+	doc, err := pdf.New(textPDF(`BT /F1 12 Tf 10 150 Td (Strength strength Strengths R\351sum\351) Tj ET`), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	for _, one := range []struct {
+		opts   *pdf.SearchOptions
+		needle string
+		hits   int
+	}{
+		{needle: "strength", hits: 3},
+		{needle: "strength", opts: &pdf.SearchOptions{ExactCase: true}, hits: 1},
+		{needle: "Strength", opts: &pdf.SearchOptions{ExactCase: true}, hits: 2},
+		{needle: "Strength", opts: &pdf.SearchOptions{WholeWord: true}, hits: 2},
+		{needle: "Strength", opts: &pdf.SearchOptions{ExactCase: true, WholeWord: true}, hits: 1},
+		{needle: "strength   Strengths", opts: &pdf.SearchOptions{ExactCase: true}, hits: 1},
+		{needle: `S\w+s`, opts: &pdf.SearchOptions{Regexp: true, ExactCase: true}, hits: 1},
+		{needle: `s\w+h`, opts: &pdf.SearchOptions{Regexp: true}, hits: 3},
+		{needle: "Resume", opts: &pdf.SearchOptions{ExactCase: true}, hits: 0},
+		{needle: "resume", opts: &pdf.SearchOptions{IgnoreDiacritics: true}, hits: 1},
+		{needle: "résumé", opts: &pdf.SearchOptions{IgnoreDiacritics: true, WholeWord: true}, hits: 1},
+	} {
+		if got := countSearchHits(t, doc, one.needle, one.opts); got != one.hits {
+			t.Errorf("expected %d hits for %q with %+v, got %d", one.hits, one.needle, one.opts, got)
+		}
+		page, renderErr := doc.RenderPageWithSearch(context.Background(), 0, 72, one.needle, one.opts)
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		if len(page.SearchHits) != one.hits {
+			t.Errorf("expected %d rendered hits for %q with %+v, got %d", one.hits, one.needle, one.opts,
+				len(page.SearchHits))
+		}
+	}
+
+	opts := &pdf.SearchOptions{Regexp: true}
+	if _, err = doc.RenderPageWithSearch(context.Background(), 0, 72, "(", opts); !errors.Is(err, pdf.ErrInvalidSearchPattern) {
+		t.Errorf("expected ErrInvalidSearchPattern from RenderPageWithSearch, got %v", err)
+	}
+	err = nil
+	for _, err = range doc.Search(context.Background(), "(", 72, opts) {
+		break
+	}
+	if !errors.Is(err, pdf.ErrInvalidSearchPattern) {
+		t.Errorf("expected ErrInvalidSearchPattern from Search, got %v", err)
+	}
+}
+
+// quadsOf returns the quads of all of the hits, in order.
+func quadsOf(hits []*pdf.SearchHit) []pdf.Quad {
+	var quads []pdf.Quad
+	for _, hit := range hits {
+		quads = append(quads, hit.Quads...)
+	}
+	return quads
+}
+
+func countSearchHits(t *testing.T, doc *pdf.Document, needle string, opts *pdf.SearchOptions) int {
+	t.Helper()
+	count := 0
+	for hits, err := range doc.Search(context.Background(), needle, 72, opts) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count += len(hits.Hits)
+	}
+	return count
+}
+
+func TestSearchHitsSpanLines(t *testing.T) {
+	// "wrapped phrase" is split across two lines, so its single match needs two quads.
+	doc, err := pdf.New(textPDF("BT /F1 12 Tf 10 150 Td (a wrapped) Tj 0 -14 Td (phrase here) Tj ET"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	for _, opts := range []*pdf.SearchOptions{nil, {ExactCase: true}} {
+		page, renderErr := doc.RenderPageWithSearch(context.Background(), 0, 72, "wrapped phrase", opts)
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		if len(page.Hits) != 1 {
+			t.Fatalf("expected 1 match with %+v, got %d", opts, len(page.Hits))
+		}
+		hit := page.Hits[0]
+		if len(hit.Quads) != 2 {
+			t.Fatalf("expected the match to span 2 quads with %+v, got %d", opts, len(hit.Quads))
+		}
+		if len(page.SearchHits) != 2 {
+			t.Fatalf("expected 2 search hit rectangles with %+v, got %d", opts, len(page.SearchHits))
+		}
+		for i, q := range hit.Quads {
+			if q.Bounds() != page.SearchHits[i] {
+				t.Errorf("quad %d with %+v: expected bounds %v, got %v", i, opts, page.SearchHits[i], q.Bounds())
+			}
+		}
+		if hit.Quads[1].UL.Y <= hit.Quads[0].UL.Y {
+			t.Errorf("expected the second quad to be below the first with %+v", opts)
+		}
+		if want := page.SearchHits[0].Union(page.SearchHits[1]); hit.Bounds() != want {
+			t.Errorf("expected hit bounds %v with %+v, got %v", want, opts, hit.Bounds())
+		}
+	}
+
+	// A match that does not fit within MaxHits is left out whole, whether MuPDF or Go does the matching.
+	for _, opts := range []*pdf.SearchOptions{{MaxHits: 1}, {MaxHits: 1, ExactCase: true}} {
+		page, renderErr := doc.RenderPageWithSearch(context.Background(), 0, 72, "wrapped phrase", opts)
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		if len(page.Hits) != 0 || len(page.SearchHits) != 0 {
+			t.Errorf("expected no partial match with %+v, got %d quads", opts, len(page.SearchHits))
+		}
+	}
+}
+
+func TestSearchResults(t *testing.T) {
+	doc, err := pdf.New(textPDF("BT /F1 12 Tf 10 150 Td (The quick brown fox) Tj 0 -14 Td (jumps over the lazy dog) Tj ET"),
+		0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	for _, one := range []struct {
+		needle  string
+		before  string
+		match   string
+		after   string
+		context int
+	}{
+		{needle: "fox", context: 10, before: "ick brown ", match: "fox", after: " jumps ove"},
+		{needle: "FOX", context: 100, before: "The quick brown ", match: "fox", after: " jumps over the lazy dog"},
+		{needle: "fox jumps", context: 2, before: "n ", match: "fox jumps", after: " o"},
+		{needle: "dog", context: 0, match: "dog"},
+	} {
+		var results []*pdf.SearchResult
+		for result, searchErr := range doc.SearchResults(context.Background(), one.needle, 72, one.context, nil) {
+			if searchErr != nil {
+				t.Fatal(searchErr)
+			}
+			results = append(results, result)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result for %q, got %d", one.needle, len(results))
+		}
+		result := results[0]
+		if result.Before != one.before || result.Match != one.match || result.After != one.after {
+			t.Errorf("expected %q [%q] %q for %q, got %q [%q] %q", one.before, one.match, one.after, one.needle,
+				result.Before, result.Match, result.After)
+		}
+		if result.PageNumber != 0 {
+			t.Errorf("expected page 0 for %q, got %d", one.needle, result.PageNumber)
+		}
+		page, renderErr := doc.RenderPageWithSearch(context.Background(), 0, 72, one.needle, nil)
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		if len(page.Hits) != 1 || len(page.Hits[0].Quads) != len(result.Quads) {
+			t.Fatalf("expected the quads for %q to match those of the rendered page", one.needle)
+		}
+		if result.Bounds() != page.Hits[0].Bounds() {
+			t.Errorf("expected bounds %v for %q, got %v", page.Hits[0].Bounds(), one.needle, result.Bounds())
+		}
+	}
+
+	count := 0
+	for _, searchErr := range doc.SearchResults(context.Background(), "o", 72, 5, &pdf.SearchOptions{MaxHits: 2}) {
+		if searchErr != nil {
+			t.Fatal(searchErr)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 results with MaxHits of 2, got %d", count)
+	}
+
+	doc.Release()
+	err = nil
+	for _, err = range doc.SearchResults(context.Background(), "fox", 72, 10, nil) {
+		break
+	}
+	if !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from SearchResults after release, got %v", err)
+	}
+}
+
+func TestSearchResultsMatchSearch(t *testing.T) {
+	doc := loadTestDocument(t)
+	for _, opts := range []*pdf.SearchOptions{nil, {WholeWord: true}} {
+		results := 0
+		for _, err := range doc.SearchResults(context.Background(), "GURPS", 72, 10, opts) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			results++
+		}
+		if want := countSearchHits(t, doc, "GURPS", opts); results == 0 || results != want {
+			t.Errorf("expected %d results with %+v, got %d", want, opts, results)
+		}
+	}
+}
+
+func TestSearchResultsUnlocatedMatch(t *testing.T) {
+	before, match, after := pdf.SearchContextOfUnlocatedMatch("some text on the page", 10)
+	if before != "" || match != "" || after != "" {
+		t.Errorf("expected no text for a match that could not be located, got %q, %q, and %q", before, match, after)
+	}
+}
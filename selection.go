@@ -0,0 +1,116 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+
+// Snaps a and b according to mode, fills quads with up to max_quads quads covering the text between them, storing how
+// many were filled in quad_count, and returns the selected text, or NULL if it threw. The caller must free the returned
+// text.
+char *wrapped_select_text(fz_context *ctx, fz_stext_page *page, fz_point a, fz_point b, int mode, fz_quad *quads, int max_quads, int *quad_count) {
+	char *text = NULL;
+	fz_var(text);
+	*quad_count = 0;
+	fz_try(ctx) {
+		fz_snap_selection(ctx, page, &a, &b, mode);
+		*quad_count = fz_highlight_selection(ctx, page, a, b, quads, max_quads);
+		text = fz_copy_selection(ctx, page, a, b, 0);
+	}
+	fz_catch(ctx) {
+		text = NULL;
+	}
+	return text;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"unsafe"
+)
+
+// SelectionMode determines how the ends of a text selection are snapped to the text.
+type SelectionMode byte
+
+// Possible values for SelectionMode.
+const (
+	// SelectChars selects from the character nearest the start point to the character nearest the end point.
+	SelectChars SelectionMode = iota
+	// SelectWords extends both ends of the selection to include whole words.
+	SelectWords
+	// SelectLines extends both ends of the selection to include whole lines.
+	SelectLines
+)
+
+// Selection holds the text selected between two points on a page.
+type Selection struct {
+	// Text is the selected text. Lines are separated by a newline. Characters that are unsafe to display, such as
+	// control characters, are removed.
+	Text string
+	// Quads holds the areas to highlight to show the selection, in rendered-image pixel space, typically one per line.
+	Quads []Quad
+}
+
+// Bounds returns the smallest image.Rectangle that encloses all of the selection's quads.
+func (s *Selection) Bounds() image.Rectangle {
+	hit := SearchHit{Quads: s.Quads}
+	return hit.Bounds()
+}
+
+func (m SelectionMode) fzMode() C.int {
+	switch m {
+	case SelectWords:
+		return C.FZ_SELECT_WORDS
+	case SelectLines:
+		return C.FZ_SELECT_LINES
+	default:
+		return C.FZ_SELECT_CHARS
+	}
+}
+
+// SelectText returns the text selected by dragging from one point to another on the specified page, as snapped by
+// mode, along with the quads to highlight to show it. The points and the returned quads are in the pixel space of the
+// page rendered at the requested dpi, as used by RenderPage. Up to OverallMaxSelectionQuads quads are returned. If ctx is
+// cancelled, the extraction is abandoned and ctx.Err() is returned.
+func (d *Document) SelectText(ctx context.Context, pageNumber int, from, to image.Point, dpi int, mode SelectionMode) (*Selection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	stext, err := d.loadStructuredText(pageNumber, 0, c)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	defer C.fz_drop_stext_page(d.ctx, stext)
+	if err = c.check(nil); err != nil {
+		return nil, err
+	}
+	scale := dpiToScale(dpi)
+	quads := make([]C.fz_quad, max(OverallMaxSelectionQuads, 1))
+	var count C.int
+	text := C.wrapped_select_text(d.ctx, stext, pointToPage(from, scale), pointToPage(to, scale), mode.fzMode(),
+		&quads[0], C.int(len(quads)), &count)
+	if text == nil {
+		return nil, ErrUnableToExtractText
+	}
+	defer C.fz_free(d.ctx, unsafe.Pointer(text))
+	selection := &Selection{Text: sanitizeText(C.GoString(text), nil)}
+	if count > 0 {
+		selection.Quads = make([]Quad, min(int(count), OverallMaxSelectionQuads))
+		for i := range selection.Quads {
+			selection.Quads[i] = scaleQuad(quads[i], scale)
+		}
+	}
+	return selection, nil
+}
+
+// pointToPage converts a point in rendered-image pixel space back into an unscaled MuPDF point.
+func pointToPage(pt image.Point, scale float64) C.fz_point {
+	return C.fz_point{x: C.float(float64(pt.X) / scale), y: C.float(float64(pt.Y) / scale)}
+}
@@ -0,0 +1,59 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestSelectText(t *testing.T) {
+	doc, err := pdf.New(textPDF(helloWorld), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+
+	// At 144 dpi, "Hello World" sits on a baseline at y=100, running from x=20 to roughly x=144, with "Hello" ending
+	// near x=75.
+	text, err := doc.PageText(context.Background(), 0, 144, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineBounds := text.Blocks[0].Lines[0].Bounds
+	for _, one := range []struct {
+		want     string
+		from, to image.Point
+		mode     pdf.SelectionMode
+	}{
+		{from: image.Pt(5, 90), to: image.Pt(190, 90), mode: pdf.SelectChars, want: "Hello World"},
+		{from: image.Pt(24, 90), to: image.Pt(40, 90), mode: pdf.SelectWords, want: "Hello"},
+		{from: image.Pt(60, 90), to: image.Pt(60, 90), mode: pdf.SelectLines, want: "Hello World"},
+	} {
+		selection, selectErr := doc.SelectText(context.Background(), 0, one.from, one.to, 144, one.mode)
+		if selectErr != nil {
+			t.Fatal(selectErr)
+		}
+		if selection.Text != one.want {
+			t.Errorf("expected %q selecting from %v to %v in mode %d, got %q", one.want, one.from, one.to, one.mode,
+				selection.Text)
+		}
+		if len(selection.Quads) != 1 {
+			t.Fatalf("expected 1 quad selecting %q, got %d", one.want, len(selection.Quads))
+		}
+		if b := selection.Bounds(); !b.Overlaps(lineBounds) || b.Min.X < lineBounds.Min.X-1 ||
+			b.Max.X > lineBounds.Max.X+1 {
+			t.Errorf("expected the bounds %v of %q to lie within the line at %v", b, one.want, lineBounds)
+		}
+	}
+
+	if _, err = doc.SelectText(context.Background(), 1, image.Point{}, image.Point{}, 144, pdf.SelectChars); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+	doc.Release()
+	if _, err = doc.SelectText(context.Background(), 0, image.Point{}, image.Point{}, 144, pdf.SelectChars); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from SelectText after release, got %v", err)
+	}
+}
@@ -0,0 +1,405 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+
+fz_stext_page *wrapped_fz_new_stext_page_from_display_list(fz_context *ctx, fz_display_list *list, int flags, fz_cookie *cookie) {
+	fz_stext_page *text = NULL;
+	fz_device *dev = NULL;
+	fz_stext_options opts = { 0 };
+	opts.flags = flags;
+	fz_var(text);
+	fz_var(dev);
+	fz_try(ctx) {
+		text = fz_new_stext_page(ctx, fz_bound_display_list(ctx, list));
+		dev = fz_new_stext_device(ctx, text, &opts);
+		fz_run_display_list(ctx, list, dev, fz_identity, fz_infinite_rect, cookie);
+		fz_close_device(ctx, dev);
+	}
+	fz_always(ctx) {
+		fz_drop_device(ctx, dev);
+	}
+	fz_catch(ctx) {
+		fz_drop_stext_page(ctx, text);
+		text = NULL;
+	}
+	return text;
+}
+
+// Returns the flattened text of the page, or NULL if it threw. The caller must drop the returned buffer.
+fz_buffer *wrapped_fz_new_buffer_from_flattened_stext_page(fz_context *ctx, fz_stext_page *text, int flatten) {
+	fz_buffer *buf = NULL;
+	fz_var(buf);
+	fz_try(ctx) {
+		buf = fz_new_buffer_from_flattened_stext_page(ctx, text, (fz_text_flatten)flatten, NULL);
+	}
+	fz_catch(ctx) {
+		buf = NULL;
+	}
+	return buf;
+}
+
+// Accessors for the members of the fz_stext_block union, which cgo cannot reach directly.
+
+fz_stext_line *stext_block_first_line(fz_stext_block *block) {
+	return block->u.t.first_line;
+}
+
+fz_stext_block *stext_block_first_child(fz_stext_block *block) {
+	return block->u.s.down != NULL ? block->u.s.down->first_block : NULL;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"unsafe"
+)
+
+// TextBlockKind identifies the kind of content a TextBlock holds.
+type TextBlockKind byte
+
+// Possible values for TextBlockKind.
+const (
+	TextBlockKindText TextBlockKind = iota
+	TextBlockKindImage
+)
+
+// TextOptions controls how the structured text of a page is extracted. A nil *TextOptions is equivalent to the zero
+// value.
+type TextOptions struct {
+	// PreserveImages adds a TextBlock of kind TextBlockKindImage for each image on the page. It has no effect on plain
+	// text export.
+	PreserveImages bool
+	// Dehyphenate joins words that were split across lines by a hyphen, removing the hyphen.
+	Dehyphenate bool
+	// PreserveLigatures keeps ligatures (such as "ﬁ") as single characters rather than expanding them into their
+	// component characters.
+	PreserveLigatures bool
+	// PreserveWhitespace keeps whitespace characters as they are rather than converting them all to spaces.
+	PreserveWhitespace bool
+	// Segment attempts to split the page into separate regions, such as columns, and orders the text by region, which
+	// usually improves the reading order of multi-column layouts. This is experimental in MuPDF.
+	Segment bool
+}
+
+// TextPage holds the structured text of a page.
+type TextPage struct {
+	Blocks []*TextBlock
+}
+
+// TextBlock holds a block of text (typically a paragraph) or, for TextBlockKindImage, the location of an image.
+type TextBlock struct {
+	// Lines is empty for image blocks.
+	Lines []*TextLine
+	// Bounds is the area covered by the block, in rendered-image pixel space.
+	Bounds image.Rectangle
+	Kind   TextBlockKind
+}
+
+// TextLine holds a line of text whose characters share a common baseline.
+type TextLine struct {
+	Spans []*TextSpan
+	// Bounds is the area covered by the line, in rendered-image pixel space.
+	Bounds image.Rectangle
+	// Vertical is true if the line was laid out with a vertical writing mode.
+	Vertical bool
+}
+
+// TextSpan holds a run of characters within a line that share the same font, size, and color.
+type TextSpan struct {
+	// Font is the name of the font as recorded in the document. Embedded subset fonts usually carry a six-letter
+	// prefix, such as "ABCDEF+Helvetica".
+	Font  string
+	Chars []*TextChar
+	// Bounds is the area covered by the span, in rendered-image pixel space.
+	Bounds image.Rectangle
+	// Size is the font size, in rendered-image pixels.
+	Size  float64
+	Color color.NRGBA
+}
+
+// TextChar holds a single character.
+type TextChar struct {
+	// Quad is the area covered by the character, in rendered-image pixel space.
+	Quad Quad
+	// Origin is the point on the baseline at which the character was drawn, in rendered-image pixel space.
+	Origin Point
+	Rune   rune
+}
+
+// Text returns the characters of the line as a string.
+func (l *TextLine) Text() string {
+	var buffer strings.Builder
+	for _, span := range l.Spans {
+		for _, ch := range span.Chars {
+			buffer.WriteRune(ch.Rune)
+		}
+	}
+	return buffer.String()
+}
+
+func (o *TextOptions) flags() C.int {
+	var flags C.int
+	if o == nil {
+		return flags
+	}
+	if o.PreserveImages {
+		flags |= C.FZ_STEXT_PRESERVE_IMAGES
+	}
+	if o.Dehyphenate {
+		flags |= C.FZ_STEXT_DEHYPHENATE
+	}
+	if o.PreserveLigatures {
+		flags |= C.FZ_STEXT_PRESERVE_LIGATURES
+	}
+	if o.PreserveWhitespace {
+		flags |= C.FZ_STEXT_PRESERVE_WHITESPACE
+	}
+	if o.Segment {
+		flags |= C.FZ_STEXT_SEGMENT
+	}
+	return flags
+}
+
+// flatten returns the MuPDF flags for converting structured text into plain text. Line and paragraph breaks are always
+// kept; hyphenated lines are only joined when dehyphenation was requested.
+func (o *TextOptions) flatten() C.int {
+	flatten := C.int(C.FZ_TEXT_FLATTEN_KEEP_LINES | C.FZ_TEXT_FLATTEN_KEEP_PARAGRAPHS)
+	if o == nil || !o.Dehyphenate {
+		flatten |= C.FZ_TEXT_FLATTEN_KEEP_HYPHENS
+	}
+	return flatten
+}
+
+// whitespace returns how the whitespace in plain text is sanitized: kept as it is when it is being preserved, and
+// otherwise turned into plain spaces, so the words on either side of it stay apart.
+func (o *TextOptions) whitespace() func(rune) rune {
+	if o != nil && o.PreserveWhitespace {
+		return func(ch rune) rune { return ch }
+	}
+	return func(rune) rune { return ' ' }
+}
+
+// PageText returns the structured text of the specified page, with all geometry in the pixel space of the page
+// rendered at the requested dpi. If ctx is cancelled, the extraction is abandoned and ctx.Err() is returned.
+func (d *Document) PageText(ctx context.Context, pageNumber, dpi int, opts *TextOptions) (*TextPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return nil, ErrDocumentReleased
+	}
+	stext, err := d.loadStructuredText(pageNumber, opts.flags(), c)
+	if err != nil {
+		return nil, c.check(err)
+	}
+	defer C.fz_drop_stext_page(d.ctx, stext)
+	if err = c.check(nil); err != nil {
+		return nil, err
+	}
+	return d.buildTextPage(stext, dpiToScale(dpi)), nil
+}
+
+// Text returns the plain text of the specified page as UTF-8. Lines are separated by a newline and paragraphs by a blank
+// line. Characters that are unsafe to display, such as control characters, are removed. If ctx is cancelled, the
+// extraction is abandoned and ctx.Err() is returned.
+func (d *Document) Text(ctx context.Context, pageNumber int, opts *TextOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	c := newCookie(ctx)
+	defer c.release()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return "", ErrDocumentReleased
+	}
+	text, err := d.plainText(pageNumber, opts, c)
+	if err = c.check(err); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// AllText returns the plain text of every page in the document, as described for Text, with the pages separated by a
+// blank line. If ctx is cancelled, the extraction is abandoned and ctx.Err() is returned. Calls into the underlying
+// MuPDF library are made one page at a time, so other calls on the document may run between pages.
+func (d *Document) AllText(ctx context.Context, opts *TextOptions) (string, error) {
+	c := newCookie(ctx)
+	defer c.release()
+	var buffer strings.Builder
+	for pageNumber := 0; ; pageNumber++ {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		text, done, err := d.nextPlainText(pageNumber, opts, c)
+		if err = c.check(err); err != nil {
+			return "", err
+		}
+		if done {
+			break
+		}
+		if text != "" {
+			if buffer.Len() != 0 {
+				buffer.WriteString("\n\n")
+			}
+			buffer.WriteString(text)
+		}
+	}
+	return buffer.String(), nil
+}
+
+// nextPlainText returns the plain text of the page for AllText, or done set to true if the page number is past the end
+// of the document.
+func (d *Document) nextPlainText(pageNumber int, opts *TextOptions, c *cookie) (text string, done bool, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.released() {
+		return "", false, ErrDocumentReleased
+	}
+	if pageNumber >= d.pageCount() {
+		return "", true, nil
+	}
+	text, err = d.plainText(pageNumber, opts, c)
+	return text, false, err
+}
+
+// plainText returns the sanitized plain text of the page. The caller must hold d.lock and must have checked that the
+// document has not been released.
+func (d *Document) plainText(pageNumber int, opts *TextOptions, c *cookie) (string, error) {
+	stext, err := d.loadStructuredText(pageNumber, opts.flags(), c)
+	if err != nil {
+		return "", err
+	}
+	defer C.fz_drop_stext_page(d.ctx, stext)
+	return d.flattenText(stext, opts)
+}
+
+// flattenText returns the sanitized plain text of the structured text. The caller must hold d.lock.
+func (d *Document) flattenText(stext *C.fz_stext_page, opts *TextOptions) (string, error) {
+	buf := C.wrapped_fz_new_buffer_from_flattened_stext_page(d.ctx, stext, opts.flatten())
+	if buf == nil {
+		return "", ErrUnableToExtractText
+	}
+	defer C.fz_drop_buffer(d.ctx, buf)
+	var data *C.uchar
+	size := C.fz_buffer_storage(d.ctx, buf, &data)
+	if data == nil || size == 0 {
+		return "", nil
+	}
+	if size > math.MaxInt32 {
+		return "", ErrUnableToExtractText
+	}
+	return sanitizeText(C.GoStringN((*C.char)(unsafe.Pointer(data)), C.int(size)), opts.whitespace()), nil
+}
+
+// loadStructuredText loads the page and extracts its structured text. The caller must hold d.lock, must have checked
+// that the document has not been released, and must drop the returned page.
+func (d *Document) loadStructuredText(pageNumber int, flags C.int, c *cookie) (*C.fz_stext_page, error) {
+	page, err := d.loadPage(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer C.fz_drop_page(d.ctx, page)
+	displayList := d.newDisplayList(page, c)
+	if displayList == nil {
+		return nil, ErrUnableToExtractText
+	}
+	defer C.fz_drop_display_list(d.ctx, displayList)
+	stext := d.newStructuredText(displayList, flags, c)
+	if stext == nil {
+		return nil, ErrUnableToExtractText
+	}
+	return stext, nil
+}
+
+// newStructuredText extracts the structured text from a display list, returning nil if that fails or is aborted through
+// c, which may be nil. The caller must hold d.lock and must drop the returned page.
+func (d *Document) newStructuredText(displayList *C.fz_display_list, flags C.int, c *cookie) *C.fz_stext_page {
+	return C.wrapped_fz_new_stext_page_from_display_list(d.ctx, displayList, flags, c.fz())
+}
+
+func (d *Document) buildTextPage(stext *C.fz_stext_page, scale float64) *TextPage {
+	fonts := make(map[*C.fz_font]string)
+	return &TextPage{Blocks: d.appendTextBlocks(nil, stext.first_block, scale, fonts)}
+}
+
+// appendTextBlocks appends the text and image blocks found in the block list to blocks. Structure blocks, which only
+// group other blocks, are flattened into the list in reading order.
+func (d *Document) appendTextBlocks(blocks []*TextBlock, block *C.fz_stext_block, scale float64, fonts map[*C.fz_font]string) []*TextBlock {
+	for ; block != nil; block = block.next {
+		switch block._type {
+		case C.FZ_STEXT_BLOCK_TEXT:
+			textBlock := &TextBlock{
+				Kind:   TextBlockKindText,
+				Bounds: rectToImage(block.bbox, scale),
+			}
+			for line := C.stext_block_first_line(block); line != nil; line = line.next {
+				textBlock.Lines = append(textBlock.Lines, d.buildTextLine(line, scale, fonts))
+			}
+			blocks = append(blocks, textBlock)
+		case C.FZ_STEXT_BLOCK_IMAGE:
+			blocks = append(blocks, &TextBlock{
+				Kind:   TextBlockKindImage,
+				Bounds: rectToImage(block.bbox, scale),
+			})
+		case C.FZ_STEXT_BLOCK_STRUCT:
+			blocks = d.appendTextBlocks(blocks, C.stext_block_first_child(block), scale, fonts)
+		default:
+		}
+	}
+	return blocks
+}
+
+func (d *Document) buildTextLine(line *C.fz_stext_line, scale float64, fonts map[*C.fz_font]string) *TextLine {
+	textLine := &TextLine{
+		Bounds:   rectToImage(line.bbox, scale),
+		Vertical: line.wmode != 0,
+	}
+	var span *TextSpan
+	for ch := line.first_char; ch != nil; ch = ch.next {
+		font, ok := fonts[ch.font]
+		if !ok {
+			if ch.font != nil {
+				font = sanitizeString(C.fz_font_name(d.ctx, ch.font))
+			}
+			fonts[ch.font] = font
+		}
+		size := float64(ch.size) * scale
+		clr := color.NRGBA{
+			R: uint8(ch.argb >> 16),
+			G: uint8(ch.argb >> 8),
+			B: uint8(ch.argb),
+			A: uint8(ch.argb >> 24),
+		}
+		if span == nil || span.Font != font || span.Size != size || span.Color != clr {
+			span = &TextSpan{
+				Font:  font,
+				Size:  size,
+				Color: clr,
+			}
+			textLine.Spans = append(textLine.Spans, span)
+		}
+		textChar := &TextChar{
+			Quad:   scaleQuad(ch.quad, scale),
+			Origin: Point{X: float64(ch.origin.x) * scale, Y: float64(ch.origin.y) * scale},
+			Rune:   rune(ch.c),
+		}
+		if len(span.Chars) == 0 {
+			span.Bounds = textChar.Quad.Bounds()
+		} else {
+			span.Bounds = span.Bounds.Union(textChar.Quad.Bounds())
+		}
+		span.Chars = append(span.Chars, textChar)
+	}
+	return textLine
+}
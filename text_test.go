@@ -0,0 +1,250 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/richardwilkes/pdf"
+)
+
+// textPDF returns a minimal single-page 200×200 document whose page draws the given content stream, with Helvetica
+// (using WinAnsiEncoding) available as /F1. No xref is supplied (startxref 0) so MuPDF rebuilds it.
+func textPDF(content string) []byte {
+	return annotatedPDF(content)
+}
+
+// annotatedPDF returns the same document as textPDF, with each of the given annotation dictionaries added to the page as
+// an indirect object.
+func annotatedPDF(content string, annots ...string) []byte {
+	var refs, objects strings.Builder
+	for i, annot := range annots {
+		fmt.Fprintf(&refs, " %d 0 R", 6+i)
+		fmt.Fprintf(&objects, "%d 0 obj\n%s\nendobj\n", 6+i, annot)
+	}
+	return fmt.Appendf(nil, `%%PDF-1.7
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R /Annots [%s] >>
+endobj
+4 0 obj
+<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>
+endobj
+5 0 obj
+<< /Length %d >>
+stream
+%s
+endstream
+endobj
+%strailer
+<< /Root 1 0 R /Size %d >>
+startxref
+0
+%%%%EOF
+`, refs.String(), len(content)+1, content, objects.String(), 6+len(annots))
+}
+
+// helloWorld draws "Hello World" in red 12pt Helvetica with its baseline origin at (10, 150), which is (10, 50) in
+// top-left/y-down image space.
+const helloWorld = "BT /F1 12 Tf 1 0 0 rg 10 150 Td (Hello World) Tj ET"
+
+func TestPageText(t *testing.T) {
+	doc, err := pdf.New(textPDF(helloWorld), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+
+	// 144 dpi => scale 2.0, so every coordinate and the font size come back doubled.
+	text, err := doc.PageText(context.Background(), 0, 144, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(text.Blocks) != 1 {
+		t.Fatalf("expected 1 block, got %d", len(text.Blocks))
+	}
+	block := text.Blocks[0]
+	if block.Kind != pdf.TextBlockKindText {
+		t.Errorf("expected a text block, got kind %d", block.Kind)
+	}
+	if len(block.Lines) != 1 {
+		t.Fatalf("expected 1 line, got %d", len(block.Lines))
+	}
+	line := block.Lines[0]
+	if got := line.Text(); got != "Hello World" {
+		t.Errorf("expected line text %q, got %q", "Hello World", got)
+	}
+	if line.Vertical {
+		t.Error("expected a horizontal line")
+	}
+	if len(line.Spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(line.Spans))
+	}
+	span := line.Spans[0]
+	if span.Font == "" {
+		t.Error("expected a font name")
+	}
+	if math.Abs(span.Size-24) > 0.01 {
+		t.Errorf("expected a font size of 24 pixels, got %v", span.Size)
+	}
+	if want := (color.NRGBA{R: 255, A: 255}); span.Color != want {
+		t.Errorf("expected color %v, got %v", want, span.Color)
+	}
+	first := span.Chars[0]
+	if first.Rune != 'H' {
+		t.Errorf("expected first rune 'H', got %q", first.Rune)
+	}
+	if math.Abs(first.Origin.X-20) > 0.01 || math.Abs(first.Origin.Y-100) > 0.01 {
+		t.Errorf("expected first origin at (20, 100), got %v", first.Origin)
+	}
+	if b := first.Quad.Bounds(); b.Min.X > 20 || b.Max.Y < 100 || b.Min.Y >= 100 {
+		t.Errorf("first character bounds %v do not sit on the baseline at (20, 100)", b)
+	}
+	if !first.Quad.Bounds().In(span.Bounds) || !span.Bounds.In(line.Bounds) || !line.Bounds.In(block.Bounds) {
+		t.Errorf("expected nested bounds, got char %v, span %v, line %v, block %v", first.Quad.Bounds(), span.Bounds,
+			line.Bounds, block.Bounds)
+	}
+
+	if _, err = doc.PageText(context.Background(), 1, 144, nil); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+	doc.Release()
+	if _, err = doc.PageText(context.Background(), 0, 144, nil); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from PageText after release, got %v", err)
+	}
+}
+
+func TestPageTextGeometryMatchesRender(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	// The structured text at a given dpi must contain a line with the searched-for word overlapping the search hit
+	// reported when rendering at that same dpi.
+	const want = "GURPS"
+	page, err := doc.RenderPage(0, 100, 1, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.SearchHits) != 1 {
+		t.Fatalf("expected 1 search hit, got %d", len(page.SearchHits))
+	}
+	text, err := doc.PageText(context.Background(), 0, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range text.Blocks {
+		for _, line := range block.Lines {
+			if strings.Contains(line.Text(), want) && line.Bounds.Overlaps(page.SearchHits[0]) {
+				return
+			}
+		}
+	}
+	t.Errorf("no line containing %q overlaps the search hit at %v", want, page.SearchHits[0])
+}
+
+func TestText(t *testing.T) {
+	doc, err := pdf.New(textPDF(helloWorld), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	text, err := doc.Text(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello World" {
+		t.Errorf("expected %q, got %q", "Hello World", text)
+	}
+	if _, err = doc.Text(context.Background(), 1, nil); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber for an out-of-range page, got %v", err)
+	}
+}
+
+func TestTextDehyphenate(t *testing.T) {
+	doc, err := pdf.New(textPDF("BT /F1 12 Tf 10 150 Td (inter-) Tj 0 -14 Td (national) Tj ET"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	text, err := doc.Text(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "inter-\n") || !strings.HasSuffix(text, "national") {
+		t.Errorf("expected the hyphenated lines to be kept without dehyphenation, got %q", text)
+	}
+	if text, err = doc.Text(context.Background(), 0, &pdf.TextOptions{Dehyphenate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "international"; text != want {
+		t.Errorf("expected %q with dehyphenation, got %q", want, text)
+	}
+}
+
+func TestTextPreserveWhitespace(t *testing.T) {
+	// \240 is a no-break space in WinAnsiEncoding.
+	doc, err := pdf.New(textPDF("BT /F1 12 Tf 10 150 Td (one\\240two) Tj ET"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	text, err := doc.Text(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "one two"; text != want {
+		t.Errorf("expected %q without preserving whitespace, got %q", want, text)
+	}
+	preserved, err := doc.Text(context.Background(), 0, &pdf.TextOptions{PreserveWhitespace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "one\u00a0two"; preserved != want {
+		t.Errorf("expected %q when preserving whitespace, got %q", want, preserved)
+	}
+	if preserved == text {
+		t.Errorf("expected preserving whitespace to change the text, got %q both times", text)
+	}
+}
+
+func TestAllText(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	all, err := doc.AllText(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range doc.PageCount() {
+		var text string
+		if text, err = doc.Text(context.Background(), i, nil); err != nil {
+			t.Fatal(err)
+		}
+		if text == "" || !strings.Contains(all, text) {
+			t.Errorf("expected the text of page %d to be non-empty and part of the document's text", i)
+		}
+	}
+	for _, ch := range all {
+		if ch != '\n' && (unicode.IsControl(ch) || !unicode.IsPrint(ch) || ch == unicode.ReplacementChar) {
+			t.Fatalf("unexpected unsafe rune %U in the document's text", ch)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = doc.AllText(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from AllText with a cancelled context, got %v", err)
+	}
+	doc.Release()
+	if _, err = doc.AllText(context.Background(), nil); !errors.Is(err, pdf.ErrDocumentReleased) {
+		t.Errorf("expected ErrDocumentReleased from AllText after release, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+*/
+import "C"
+
+import (
+	"context"
+	"image"
+	"math"
+)
+
+// RenderTile renders just the part of the specified page within clip, which is in the pixel space of the page rendered
+// at scale, where a scale of 1 is 72 dpi. Unlike the other render methods, scale is not capped, so a small region of a
+// page can be rendered at a very high zoom without producing a whole-page image. The returned image is the size of
+// clip, with any part of clip that lies outside the page left transparent. If needle is not empty, the text that
+// matches it, as controlled by opts, is searched for across the whole page, and the matches that overlap the tile are
+// returned; they keep the Match numbers they have for the whole page. The search hits and link bounds are relative to
+// the tile, that is, offset by -clip.Min. If ctx is cancelled, the render is abandoned and ctx.Err() is returned.
+func (d *Document) RenderTile(ctx context.Context, pageNumber int, scale float64, clip image.Rectangle, needle string, opts *SearchOptions) (*RenderedPage, error) {
+	s, err := newTileSearcher(scale, clip, needle, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.runRender(ctx, nil, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		rendered, job, prepareErr := d.prepareRender(pageNumber, s, opts.maxHits(), func(*C.fz_page) (Matrix, error) {
+			return ScaleMatrix(scale, scale), nil
+		}, c)
+		if prepareErr != nil {
+			return nil, nil, prepareErr
+		}
+		clipToTile(rendered, job, clip)
+		return rendered, job, nil
+	})
+}
+
+// RenderTile renders just the part of the page within clip, as RenderTile does for a Document.
+func (p *Page) RenderTile(ctx context.Context, scale float64, clip image.Rectangle, needle string, opts *SearchOptions) (*RenderedPage, error) {
+	s, err := newTileSearcher(scale, clip, needle, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p.doc.runRender(ctx, nil, func(c *cookie) (*RenderedPage, *renderJob, error) {
+		p.owner.lock.Lock()
+		defer p.owner.lock.Unlock()
+		if err = p.releasedErr(); err != nil {
+			return nil, nil, err
+		}
+		rendered, job, prepareErr := p.doc.prepareJob(p.displayList, p.links, p.number, ScaleMatrix(scale, scale), s, opts.maxHits(), c)
+		if prepareErr != nil {
+			return nil, nil, prepareErr
+		}
+		clipToTile(rendered, job, clip)
+		return rendered, job, nil
+	})
+}
+
+// newTileSearcher validates the tile and returns the searcher for needle.
+func newTileSearcher(scale float64, clip image.Rectangle, needle string, opts *SearchOptions) (*searcher, error) {
+	if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) || clip.Empty() || clip.Min.X < math.MinInt32 ||
+		clip.Min.Y < math.MinInt32 || clip.Max.X > math.MaxInt32 || clip.Max.Y > math.MaxInt32 {
+		return nil, ErrInvalidTile
+	}
+	return newSearcher(needle, opts)
+}
+
+// clipToTile restricts a job prepared for the whole page to the tile, moving the search hits and links that overlap the
+// tile into its pixel space and dropping the rest.
+func clipToTile(rendered *RenderedPage, job *renderJob, clip image.Rectangle) {
+	job.bbox = C.fz_irect{
+		x0: C.int(clip.Min.X),
+		y0: C.int(clip.Min.Y),
+		x1: C.int(clip.Max.X),
+		y1: C.int(clip.Max.Y),
+	}
+	// SearchHits is rebuilt from the hits that are kept, so that it still holds the bounds of each of their quads, in
+	// order, even when only some of the quads of a match overlap the tile.
+	var boxes []image.Rectangle
+	var hits []*SearchHit
+	for _, hit := range rendered.Hits {
+		if !hit.Bounds().Overlaps(clip) {
+			continue
+		}
+		for i := range hit.Quads {
+			hit.Quads[i] = hit.Quads[i].offset(-float64(clip.Min.X), -float64(clip.Min.Y))
+			boxes = append(boxes, hit.Quads[i].Bounds())
+		}
+		hits = append(hits, hit)
+	}
+	rendered.SearchHits = boxes
+	rendered.Hits = hits
+	var links []*PageLink
+	for _, link := range rendered.Links {
+		if link.Bounds.Overlaps(clip) {
+			link.Bounds = link.Bounds.Sub(clip.Min)
+			links = append(links, link)
+		}
+	}
+	rendered.Links = links
+}
+
+func (q Quad) offset(dx, dy float64) Quad {
+	return Quad{
+		UL: Point{X: q.UL.X + dx, Y: q.UL.Y + dy},
+		UR: Point{X: q.UR.X + dx, Y: q.UR.Y + dy},
+		LL: Point{X: q.LL.X + dx, Y: q.LL.Y + dy},
+		LR: Point{X: q.LR.X + dx, Y: q.LR.Y + dy},
+	}
+}
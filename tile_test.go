@@ -0,0 +1,152 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestRenderTile(t *testing.T) {
+	doc := loadTestDocument(t)
+	full, err := doc.RenderPageWithSearch(context.Background(), 0, 144, "GURPS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.SearchHits) == 0 {
+		t.Fatal("expected search hits on page 0")
+	}
+
+	// A tile around the first hit must show the same pixels as that part of the whole page, with the hit moved into
+	// the tile's pixel space.
+	hit := full.SearchHits[0]
+	clip := image.Rect(hit.Min.X-20, hit.Min.Y-20, hit.Min.X+236, hit.Min.Y+236).Intersect(full.Image.Rect)
+	tile, err := doc.RenderTile(context.Background(), 0, 2, clip, "GURPS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tile.Image.Rect != image.Rect(0, 0, clip.Dx(), clip.Dy()) {
+		t.Fatalf("expected a %dx%d image, got %v", clip.Dx(), clip.Dy(), tile.Image.Rect)
+	}
+	differences := 0
+	for y := range clip.Dy() {
+		for x := range clip.Dx() {
+			want := full.Image.NRGBAAt(clip.Min.X+x, clip.Min.Y+y)
+			got := tile.Image.NRGBAAt(x, y)
+			if absDiff(want.R, got.R) > 2 || absDiff(want.G, got.G) > 2 || absDiff(want.B, got.B) > 2 ||
+				absDiff(want.A, got.A) > 2 {
+				differences++
+			}
+		}
+	}
+	if differences > clip.Dx()*clip.Dy()/100 {
+		t.Errorf("%d pixels of the tile differ from the whole page", differences)
+	}
+	found := false
+	for _, box := range tile.SearchHits {
+		if box == hit.Sub(clip.Min) {
+			found = true
+		}
+		if !box.Overlaps(tile.Image.Rect) {
+			t.Errorf("search hit %v lies outside of the tile", box)
+		}
+	}
+	if !found {
+		t.Errorf("expected the tile's search hits to include %v", hit.Sub(clip.Min))
+	}
+	for _, one := range tile.Hits {
+		if !one.Bounds().Overlaps(tile.Image.Rect) {
+			t.Errorf("hit %v lies outside of the tile", one.Bounds())
+		}
+	}
+
+	// A deep zoom tile is limited by its own size, not that of the whole page.
+	page, err := doc.LoadPage(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Release()
+	deep, err := page.RenderTile(context.Background(), 1200.0/72, image.Rect(4096, 4096, 4608, 4608), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deep.Image.Rect != image.Rect(0, 0, 512, 512) {
+		t.Errorf("expected a 512x512 image, got %v", deep.Image.Rect)
+	}
+
+	// Parts of the tile beyond the page are transparent.
+	outside, err := page.RenderTile(context.Background(), 1, image.Rect(-64, -64, 0, 0), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := outside.Image.NRGBAAt(10, 10); c.A != 0 {
+		t.Errorf("expected a transparent pixel outside of the page, got %v", c)
+	}
+}
+
+func TestRenderTileSpanningMatch(t *testing.T) {
+	// "wrapped phrase" is split across two lines, so its single match needs two quads.
+	doc, err := pdf.New(textPDF("BT /F1 12 Tf 10 150 Td (a wrapped) Tj 0 -14 Td (phrase here) Tj ET"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Release()
+	full, err := doc.RenderPageWithSearch(context.Background(), 0, 72, "wrapped phrase", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Hits) != 1 || len(full.Hits[0].Quads) != 2 {
+		t.Fatalf("expected 1 match with 2 quads, got %d", len(full.Hits))
+	}
+
+	// The tile ends partway through the first line, so the match crosses its edge and the second quad lies beyond it.
+	first := full.Hits[0].Quads[0].Bounds()
+	clip := image.Rect(0, 0, 200, first.Min.Y+first.Dy()/2)
+	tile, err := doc.RenderTile(context.Background(), 0, 1, clip, "wrapped phrase", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tile.Hits) != 1 || len(tile.Hits[0].Quads) != 2 {
+		t.Fatalf("expected the whole match to be kept, got %d", len(tile.Hits))
+	}
+	if len(tile.SearchHits) != len(tile.Hits[0].Quads) {
+		t.Fatalf("expected a search hit for each of the %d quads, got %d", len(tile.Hits[0].Quads),
+			len(tile.SearchHits))
+	}
+	for i, q := range tile.Hits[0].Quads {
+		if tile.SearchHits[i] != q.Bounds() {
+			t.Errorf("search hit %d: expected %v, got %v", i, q.Bounds(), tile.SearchHits[i])
+		}
+		if want := full.SearchHits[i].Sub(clip.Min); tile.SearchHits[i] != want {
+			t.Errorf("search hit %d: expected %v, got %v", i, want, tile.SearchHits[i])
+		}
+	}
+}
+
+func TestRenderTileErrors(t *testing.T) {
+	doc := loadTestDocument(t)
+	for _, one := range []struct {
+		clip  image.Rectangle
+		scale float64
+	}{
+		{clip: image.Rectangle{}, scale: 1},
+		{clip: image.Rect(0, 0, 100, 100), scale: 0},
+		{clip: image.Rect(0, 0, 100, 100), scale: -1},
+	} {
+		if _, err := doc.RenderTile(context.Background(), 0, one.scale, one.clip, "", nil); !errors.Is(err, pdf.ErrInvalidTile) {
+			t.Errorf("expected ErrInvalidTile for a scale of %v and clip of %v, got %v", one.scale, one.clip, err)
+		}
+	}
+	if _, err := doc.RenderTile(context.Background(), -1, 1, image.Rect(0, 0, 10, 10), "", nil); !errors.Is(err, pdf.ErrInvalidPageNumber) {
+		t.Errorf("expected ErrInvalidPageNumber, got %v", err)
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
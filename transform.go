@@ -0,0 +1,187 @@
+package pdf
+
+/*
+#include <mupdf/fitz.h>
+*/
+import "C"
+
+import (
+	"image"
+	"math"
+)
+
+// Matrix is an affine transform from the coordinate space of a page, in points (1/72 of an inch) with y increasing
+// downward, to the pixel space of a rendered page. The point (x, y) is transformed to (x*A + y*C + E, x*B + y*D + F),
+// just as MuPDF's fz_matrix does.
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+// ScaleMatrix returns a Matrix that scales by sx horizontally and sy vertically. A negative value flips that axis.
+func ScaleMatrix(sx, sy float64) Matrix {
+	return Matrix{A: sx, D: sy}
+}
+
+// RotateMatrix returns a Matrix that rotates by degrees, clockwise as seen on the rendered page. Multiples of 90 degrees
+// are exact.
+func RotateMatrix(degrees float64) Matrix {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+	var s, c float64
+	switch degrees {
+	case 0:
+		c = 1
+	case 90:
+		s = 1
+	case 180:
+		c = -1
+	case 270:
+		s = -1
+	default:
+		s, c = math.Sincos(degrees * math.Pi / 180)
+	}
+	return Matrix{A: c, B: s, C: -s, D: c}
+}
+
+// Concat returns a Matrix that applies m and then n.
+func (m Matrix) Concat(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.B*n.C,
+		B: m.A*n.B + m.B*n.D,
+		C: m.C*n.A + m.D*n.C,
+		D: m.C*n.B + m.D*n.D,
+		E: m.E*n.A + m.F*n.C + n.E,
+		F: m.E*n.B + m.F*n.D + n.F,
+	}
+}
+
+// Apply returns p transformed by m.
+func (m Matrix) Apply(p Point) Point {
+	return Point{X: p.X*m.A + p.Y*m.C + m.E, Y: p.X*m.B + p.Y*m.D + m.F}
+}
+
+// valid reports whether m is finite and can be inverted, so that it does not collapse the page.
+func (m Matrix) valid() bool {
+	for _, v := range []float64{m.A, m.B, m.C, m.D, m.E, m.F} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return m.A*m.D-m.B*m.C != 0
+}
+
+// isScale reports whether m does no more than scale each axis by a positive amount, so that every page keeps its
+// origin in the top-left corner.
+func (m Matrix) isScale() bool {
+	return m.B == 0 && m.C == 0 && m.E == 0 && m.F == 0 && m.A > 0 && m.D > 0
+}
+
+func (m Matrix) fz() C.fz_matrix {
+	return C.fz_matrix{
+		a: C.float(m.A),
+		b: C.float(m.B),
+		c: C.float(m.C),
+		d: C.float(m.D),
+		e: C.float(m.E),
+		f: C.float(m.F),
+	}
+}
+
+// fitTo returns m with its translation replaced by the one that moves the top-left corner of bounds, once transformed,
+// to the origin, so that the rendered page starts at 0,0 however it has been rotated or flipped.
+func (m Matrix) fitTo(bounds C.fz_rect) Matrix {
+	m.E = 0
+	m.F = 0
+	corners := m.quad(C.fz_quad_from_rect(bounds))
+	m.E = -math.Min(math.Min(corners.UL.X, corners.UR.X), math.Min(corners.LL.X, corners.LR.X))
+	m.F = -math.Min(math.Min(corners.UL.Y, corners.UR.Y), math.Min(corners.LL.Y, corners.LR.Y))
+	return m
+}
+
+// quad transforms a MuPDF quad by m into rendered-image pixel space. No rounding is applied, so the corners keep their
+// exact positions.
+func (m Matrix) quad(q C.fz_quad) Quad {
+	return Quad{
+		UL: m.Apply(Point{X: float64(q.ul.x), Y: float64(q.ul.y)}),
+		UR: m.Apply(Point{X: float64(q.ur.x), Y: float64(q.ur.y)}),
+		LL: m.Apply(Point{X: float64(q.ll.x), Y: float64(q.ll.y)}),
+		LR: m.Apply(Point{X: float64(q.lr.x), Y: float64(q.lr.y)}),
+	}
+}
+
+// quadBounds returns the axis-aligned bounding rectangle of a MuPDF quad transformed by m, expanding outward as
+// scaleRect does.
+func (m Matrix) quadBounds(q C.fz_quad) image.Rectangle {
+	t := m.quad(q)
+	return image.Rect(
+		int(math.Floor(math.Min(math.Min(t.UL.X, t.UR.X), math.Min(t.LL.X, t.LR.X)))),
+		int(math.Floor(math.Min(math.Min(t.UL.Y, t.UR.Y), math.Min(t.LL.Y, t.LR.Y)))),
+		int(math.Ceil(math.Max(math.Max(t.UL.X, t.UR.X), math.Max(t.LL.X, t.LR.X)))),
+		int(math.Ceil(math.Max(math.Max(t.UL.Y, t.UR.Y), math.Max(t.LL.Y, t.LR.Y)))),
+	)
+}
+
+// rect returns the axis-aligned bounding rectangle of a MuPDF rect transformed by m, expanding outward as scaleRect
+// does.
+func (m Matrix) rect(r C.fz_rect) image.Rectangle {
+	return m.quadBounds(C.fz_quad_from_rect(r))
+}
+
+// dest transforms a destination point by m and floors it. As with scaledFloor, a coordinate MuPDF leaves non-finite
+// because the destination does not specify it is treated as 0, as is a result that does not fit in an int.
+func (m Matrix) dest(p C.fz_point) image.Point {
+	x := float64(p.x)
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		x = 0
+	}
+	y := float64(p.y)
+	if math.IsNaN(y) || math.IsInf(y, 0) {
+		y = 0
+	}
+	t := m.Apply(Point{X: x, Y: y})
+	return image.Pt(scaledFloor(t.X, 1), scaledFloor(t.Y, 1))
+}
+
+// transformMatches converts unscaled matches into hits in rendered-image pixel space, transforming them by m and
+// numbering them from first.
+func transformMatches(matches [][]C.fz_quad, m Matrix, first int) []*SearchHit {
+	if len(matches) == 0 {
+		return nil
+	}
+	hits := make([]*SearchHit, len(matches))
+	for i, match := range matches {
+		hit := &SearchHit{
+			Quads: make([]Quad, len(match)),
+			Match: first + i,
+		}
+		for j, q := range match {
+			hit.Quads[j] = m.quad(q)
+		}
+		hits[i] = hit
+	}
+	return hits
+}
+
+// transformLinks converts resolved links into PageLinks in the pixel space of the page rendered with m, which must
+// already be fitted to the page. Each destination is transformed by destFor for its page.
+func transformLinks(links []resolvedLink, m Matrix, destFor func(pageNumber int) Matrix) []*PageLink {
+	if len(links) == 0 {
+		return nil
+	}
+	pageLinks := make([]*PageLink, len(links))
+	for i, link := range links {
+		pageLink := &PageLink{
+			URI:        link.uri,
+			PageLabel:  link.label,
+			PageNumber: link.pageNumber,
+			Bounds:     m.rect(link.rect),
+		}
+		if link.uri == "" {
+			pageLink.DestPoint = destFor(link.pageNumber).dest(link.dest)
+		}
+		pageLinks[i] = pageLink
+	}
+	return pageLinks
+}
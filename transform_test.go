@@ -0,0 +1,134 @@
+package pdf_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/pdf"
+)
+
+func TestMatrix(t *testing.T) {
+	m := pdf.ScaleMatrix(2, 3).Concat(pdf.RotateMatrix(90))
+	if got := m.Apply(pdf.Point{X: 1, Y: 1}); got != (pdf.Point{X: -3, Y: 2}) {
+		t.Errorf("expected (-3, 2), got %v", got)
+	}
+	if got := pdf.RotateMatrix(-90); got != pdf.RotateMatrix(270) {
+		t.Errorf("expected -90 degrees to match 270 degrees, got %+v", got)
+	}
+	got := pdf.RotateMatrix(45).Concat(pdf.RotateMatrix(45))
+	want := pdf.RotateMatrix(90)
+	for _, pair := range [][2]float64{{got.A, want.A}, {got.B, want.B}, {got.C, want.C}, {got.D, want.D}} {
+		if math.Abs(pair[0]-pair[1]) > 1e-9 {
+			t.Errorf("expected two 45 degree rotations to make %+v, got %+v", want, got)
+			break
+		}
+	}
+}
+
+func TestRenderWithTransform(t *testing.T) {
+	doc := loadTestDocument(t)
+	upright, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{DPI: 100, Needle: "GURPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upright.SearchHits) == 0 {
+		t.Fatal("expected search hits on page 0")
+	}
+	bounds := upright.Image.Rect
+
+	for _, rotation := range []float64{90, 180, 270} {
+		rotated, renderErr := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{
+			DPI:      100,
+			Rotation: rotation,
+			Needle:   "GURPS",
+		})
+		if renderErr != nil {
+			t.Fatal(renderErr)
+		}
+		want := bounds
+		if rotation != 180 {
+			want = image.Rect(0, 0, bounds.Dy(), bounds.Dx())
+		}
+		if rotated.Image.Rect != want {
+			t.Errorf("rotation %v: expected bounds %v, got %v", rotation, want, rotated.Image.Rect)
+		}
+		if len(rotated.SearchHits) != len(upright.SearchHits) {
+			t.Errorf("rotation %v: expected %d search hits, got %d", rotation, len(upright.SearchHits),
+				len(rotated.SearchHits))
+		}
+		// The hits must still land on the rotated page, and keep their size with their sides swapped as needed.
+		for i, box := range rotated.SearchHits {
+			if !box.In(rotated.Image.Rect) {
+				t.Errorf("rotation %v: search hit %v lies outside of the page", rotation, box)
+			}
+			size := upright.SearchHits[i].Size()
+			if rotation != 180 {
+				size = image.Pt(size.Y, size.X)
+			}
+			if got := box.Size(); absInt(got.X-size.X) > 1 || absInt(got.Y-size.Y) > 1 {
+				t.Errorf("rotation %v: expected search hit %d to be about %v, got %v", rotation, i, size, got)
+			}
+		}
+		for _, link := range rotated.Links {
+			if !link.Bounds.Overlaps(rotated.Image.Rect) {
+				t.Errorf("rotation %v: link %v lies outside of the page", rotation, link.Bounds)
+			}
+		}
+	}
+
+	stretched, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{XDPI: 72, YDPI: 144})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := doc.RenderPage(0, 72, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stretched.Image.Rect; got.Dx() != plain.Image.Rect.Dx() || absInt(got.Dy()-2*plain.Image.Rect.Dy()) > 1 {
+		t.Errorf("expected twice the height of %v, got %v", plain.Image.Rect, got)
+	}
+
+	flip := pdf.ScaleMatrix(-1, 1)
+	flipped, err := doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{Transform: &flip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flipped.Image.Rect != plain.Image.Rect {
+		t.Errorf("expected a flipped page to keep bounds %v, got %v", plain.Image.Rect, flipped.Image.Rect)
+	}
+
+	collapsed := pdf.ScaleMatrix(1, 0)
+	if _, err = doc.RenderPageWithOptions(context.Background(), 0, &pdf.RenderOptions{Transform: &collapsed}); !errors.Is(err, pdf.ErrInvalidTransform) {
+		t.Errorf("expected ErrInvalidTransform, got %v", err)
+	}
+}
+
+func TestTableOfContentsWithOptions(t *testing.T) {
+	doc := loadTestDocument(t)
+	want := doc.TableOfContents(72)
+	got := doc.TableOfContentsWithOptions(nil)
+	if len(got) == 0 || len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].PageNumber != want[i].PageNumber || got[i].PageX != want[i].PageX || got[i].PageY != want[i].PageY {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	rotated := doc.TableOfContentsWithOptions(&pdf.RenderOptions{Rotation: 180})
+	for i, entry := range rotated {
+		if entry.PageNumber != want[i].PageNumber || entry.Title != want[i].Title {
+			t.Errorf("entry %d: expected the same target as %+v, got %+v", i, want[i], entry)
+		}
+	}
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}